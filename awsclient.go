@@ -0,0 +1,156 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	awsAccessKeyIDEnv     = "AWS_ACCESS_KEY_ID"
+	awsSecretAccessKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	awsSessionTokenEnv    = "AWS_SESSION_TOKEN"
+	awsRegionEnv          = "AWS_REGION"
+
+	awsJSONContentType = "application/x-amz-json-1.1"
+	sigV4Algorithm     = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat    = "20060102T150405Z"
+)
+
+// awsAPI calls operations of AWS services speaking the JSON 1.1 protocol, such as SSM and Secrets Manager.
+type awsAPI interface {
+	// call calls the operation target, e.g. AmazonSSM.GetParameter, of service with the JSON encoding of in and decodes
+	// the response into out.
+	call(ctx context.Context, service, target string, in, out interface{}) error
+}
+
+// awsCredentials are the credentials of the execution role of the lambda function.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// envAWSCredentials returns the credentials the Lambda runtime sets as environment variables.
+func envAWSCredentials() awsCredentials {
+	return awsCredentials{
+		accessKeyID:     os.Getenv(awsAccessKeyIDEnv),
+		secretAccessKey: os.Getenv(awsSecretAccessKeyEnv),
+		sessionToken:    os.Getenv(awsSessionTokenEnv),
+	}
+}
+
+// awsJSONClient is the awsAPI implementation sending requests signed with AWS Signature Version 4. endpoint, if set,
+// replaces the regional endpoint https://service.region.amazonaws.com of every service, e.g. for a local stand-in.
+type awsJSONClient struct {
+	region      string
+	endpoint    string
+	credentials func() awsCredentials
+	client      *http.Client
+	now         func() time.Time
+}
+
+func newAWSJSONClient(client *http.Client) *awsJSONClient {
+	return &awsJSONClient{region: os.Getenv(awsRegionEnv), credentials: envAWSCredentials, client: client, now: time.Now}
+}
+
+func (c *awsJSONClient) call(ctx context.Context, service, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("cannot encode %s request. %+v", target, err)
+	}
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = "https://" + service + "." + c.region + ".amazonaws.com/"
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create %s request to %s. %+v", target, endpoint, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", awsJSONContentType)
+	req.Header.Set("X-Amz-Target", target)
+	signV4(req, body, c.credentials(), c.region, service, c.now())
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request. %+v", target, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read %s response. %+v", target, err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("invalid %s response status code %d: %s", target, resp.StatusCode, respBody)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("cannot decode %s response. %+v", target, err)
+	}
+	return nil
+}
+
+// signV4 signs req with body for region and service with AWS Signature Version 4, setting the X-Amz-Date,
+// X-Amz-Security-Token and Authorization headers. All headers set on req before are signed.
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(sigV4TimeFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		values := make([]string, len(vs))
+		for i, v := range vs {
+			// Canonical header values are trimmed, with sequential spaces collapsed into one.
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(k)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders,
+		sha256Hex(body)}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigV4Algorithm,
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
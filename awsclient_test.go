@@ -0,0 +1,47 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignV4(t *testing.T) {
+	body := []byte(`{"Name":"token"}`)
+	req, err := http.NewRequest("POST", "https://ssm.us-east-1.amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", awsJSONContentType)
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	signV4(req, body, awsCredentials{accessKeyID: "AKID", secretAccessKey: "SECRET"}, "us-east-1", "ssm", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKID/20190101/us-east-1/ssm/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date;x-amz-target, " +
+		"Signature=0cb6406164a0420f9aa915de27c5d78deff9a8f104e9bfcf28b1ec07e19b1b67"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("want authorization %s got %s", want, got)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20190101T000000Z" {
+		t.Errorf("want date 20190101T000000Z got %s", got)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "" {
+		t.Errorf("want no security token header without session token")
+	}
+}
+
+func TestSignV4HeaderWhitespace(t *testing.T) {
+	var authorizations []string
+	for _, value := range []string{"a b  c", "  a   b c ", "a b c"} {
+		req, err := http.NewRequest("POST", "https://ssm.us-east-1.amazonaws.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Custom", value)
+		signV4(req, nil, awsCredentials{accessKeyID: "AKID", secretAccessKey: "SECRET"}, "us-east-1", "ssm", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+	}
+	if authorizations[0] != authorizations[2] || authorizations[1] != authorizations[2] {
+		t.Errorf("want same signature for header values differing in whitespace got %v", authorizations)
+	}
+}
@@ -0,0 +1,41 @@
+package sfxlambda
+
+import (
+	"encoding/json"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+// batchResponse holds the partial batch failures reported by the handler of an SQS, Kinesis or DynamoDB Streams batch.
+type batchResponse struct {
+	BatchItemFailures []struct {
+		ItemIdentifier string `json:"itemIdentifier"`
+	} `json:"batchItemFailures"`
+}
+
+// batchDatapoints returns the batch.items_failed and batch.items_succeeded counters of an SQS, Kinesis or DynamoDB
+// Streams batch invocation. The failed items are the batchItemFailures of the handler response, or all items if the
+// handler returns an error.
+func batchDatapoints(ev *eventPayload, response []byte, err error) []*datapoint.Datapoint {
+	switch ev.eventSource() {
+	case sqsSource, kinesisSource, dynamoDBSource:
+	default:
+		return nil
+	}
+	items := int64(len(ev.Records))
+	failed := items
+	if err == nil {
+		var r batchResponse
+		if json.Unmarshal(response, &r) != nil {
+			r.BatchItemFailures = nil
+		}
+		failed = int64(len(r.BatchItemFailures))
+		if failed > items {
+			failed = items
+		}
+	}
+	return []*datapoint.Datapoint{
+		{Metric: "batch.items_failed", Value: datapoint.NewIntValue(failed), MetricType: datapoint.Counter},
+		{Metric: "batch.items_succeeded", Value: datapoint.NewIntValue(items - failed), MetricType: datapoint.Counter},
+	}
+}
@@ -0,0 +1,39 @@
+package sfxlambda
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestBatchDatapoints(t *testing.T) {
+	var tests = []struct {
+		payload       string
+		response      string
+		err           error
+		wantFailed    int64
+		wantSucceeded int64
+	}{
+		{sqsEvent, `{"batchItemFailures":[{"itemIdentifier":"2e1424d4-f796-459a-8184-9c92662be6da"}]}`, nil, 1, 1},
+		{sqsEvent, `{"batchItemFailures":[]}`, nil, 0, 2},
+		{sqsEvent, `null`, nil, 0, 2},
+		{kinesisEvent, ``, errors.New("failed"), 2, 0},
+		{dynamoDBEvent, `{"batchItemFailures":[{"itemIdentifier":"111"}]}`, nil, 1, 0},
+	}
+	for i, test := range tests {
+		dps := batchDatapoints(parseEventPayload([]byte(test.payload)), []byte(test.response), test.err)
+		if len(dps) != 2 {
+			t.Fatalf("test %d. want 2 datapoints got %d", i, len(dps))
+		}
+		if got := dps[0].Value.(datapoint.IntValue).Int(); got != test.wantFailed {
+			t.Errorf("test %d. want %d failed items got %d", i, test.wantFailed, got)
+		}
+		if got := dps[1].Value.(datapoint.IntValue).Int(); got != test.wantSucceeded {
+			t.Errorf("test %d. want %d succeeded items got %d", i, test.wantSucceeded, got)
+		}
+	}
+	if dps := batchDatapoints(parseEventPayload([]byte(`{"httpMethod":"GET","resource":"/"}`)), nil, nil); len(dps) != 0 {
+		t.Errorf("want no datapoints for non batch event got %+v", dps)
+	}
+}
@@ -0,0 +1,93 @@
+package sfxlambda
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/trace"
+)
+
+// TestConcurrentInvocations is meant to be run with the race detector, go test -race.
+func TestConcurrentInvocations(t *testing.T) {
+	savedSendDatapoints, savedSendSpans, savedTracingEnabled := sendDatapoints, sendSpans, tracingEnabled
+	defer func() {
+		sendDatapoints, sendSpans, tracingEnabled = savedSendDatapoints, savedSendSpans, savedTracingEnabled
+	}()
+	var mu sync.Mutex
+	var sends [][]*datapoint.Datapoint
+	sendDatapoints = func(_ context.Context, dps []*datapoint.Datapoint) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sends = append(sends, dps)
+		return nil
+	}
+	sendSpans = func(context.Context, []*trace.Span) error {
+		return nil
+	}
+	tracingEnabled = true
+	var hw ContextHandlerWrapper
+	hw = NewHandlerWrapper(lambda.NewHandler(func(ctx context.Context, i int) error {
+		span, ctx := StartSpan(ctx, "work")
+		defer span.Finish()
+		if err := RecordDatapoints(ctx, []*datapoint.Datapoint{{Metric: "work", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter}}); err != nil {
+			return err
+		}
+		return hw.SendDatapointsContext(ctx, []*datapoint.Datapoint{{Metric: "custom", Value: datapoint.NewIntValue(1),
+			MetricType: datapoint.Counter, Dimensions: map[string]string{"invocation": fmt.Sprint(i)}}})
+	}))
+	const invocations = 50
+	var wg sync.WaitGroup
+	for i := 0; i < invocations; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := hw.Invoke(newCtx(fmt.Sprintf("arn:aws:lambda:us-east-1:accountId:function:function-%d", i)), []byte(fmt.Sprint(i))); err != nil {
+				t.Errorf("valid lambda handler function invocation error. got %+v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	coldStarts, customs := 0, 0
+	arns := map[string]int{}
+	for _, dps := range sends {
+		if len(dps) == 0 {
+			continue
+		}
+		arn := dps[0].Dimensions["lambda_arn"]
+		if dps[0].Metric == "custom" {
+			customs++
+			if want := "function-" + dps[0].Dimensions["invocation"]; !strings.Contains(arn, ":"+want+":") {
+				t.Errorf("want custom datapoint of invocation %s with lambda_arn of %s got %s", dps[0].Dimensions["invocation"], want, arn)
+			}
+			continue
+		}
+		arns[arn]++
+		for _, dp := range dps {
+			if dp.Dimensions["lambda_arn"] != arn {
+				t.Errorf("want lambda_arn %s for all datapoints of an invocation got %s", arn, dp.Dimensions["lambda_arn"])
+			}
+			if dp.Metric == "function.cold_starts" {
+				coldStarts++
+			}
+		}
+	}
+	if customs != invocations {
+		t.Errorf("want custom datapoints of %d invocations got %d", invocations, customs)
+	}
+	if coldStarts != 1 {
+		t.Errorf("want exactly 1 cold start datapoint got %d", coldStarts)
+	}
+	if len(arns) != invocations {
+		t.Errorf("want datapoints of %d invocations got %d", invocations, len(arns))
+	}
+	for arn, n := range arns {
+		if n != 1 {
+			t.Errorf("want 1 send for %s got %d", arn, n)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package sfxlambda
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/sfxclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// Config is the configuration of the wrapper read from the environment variables when the function starts.
+type Config struct {
+	// AuthToken is the value of SIGNALFX_AUTH_TOKEN.
+	AuthToken string
+	// AuthTokenSource is the value of SIGNALFX_AUTH_TOKEN_SOURCE.
+	AuthTokenSource string
+	// DatapointEndpoint, EventEndpoint and TraceEndpoint are the ingest endpoints derived from SIGNALFX_INGEST_ENDPOINT,
+	// SIGNALFX_REALM and their overrides.
+	DatapointEndpoint string
+	EventEndpoint     string
+	TraceEndpoint     string
+	// SendTimeout is the value of SIGNALFX_SEND_TIMEOUT_SECONDS.
+	SendTimeout time.Duration
+	// MetricsTransport and TracesTransport are the values of SIGNALFX_METRICS_TRANSPORT and SIGNALFX_TRACES_TRANSPORT.
+	MetricsTransport string
+	TracesTransport  string
+	// DatapointFormat is the value of SIGNALFX_DATAPOINT_FORMAT.
+	DatapointFormat string
+	// TraceSampleRate is the value of SIGNALFX_TRACE_SAMPLE_RATE.
+	TraceSampleRate float64
+	// TraceSlowThreshold is the value of SIGNALFX_TRACE_SAMPLE_SLOW_MS.
+	TraceSlowThreshold time.Duration
+	// TraceRateLimit is the value of SIGNALFX_TRACE_RATE_LIMIT.
+	TraceRateLimit float64
+	// AuthTokenRefresh is the value of SIGNALFX_AUTH_TOKEN_REFRESH_SECONDS.
+	AuthTokenRefresh time.Duration
+	// ForwarderBatchSize is the value of SIGNALFX_FORWARDER_BATCH_SIZE.
+	ForwarderBatchSize int
+	// StatsdMaxPacketSize is the value of SIGNALFX_STATSD_MAX_PACKET_SIZE.
+	StatsdMaxPacketSize int
+	// Features are the names of the enabled optional features, e.g. tracing.
+	Features []string
+	// Strict is the value of SIGNALFX_STRICT_CONFIG. In strict mode an invalid configuration fails the cold start.
+	Strict bool
+
+	enabledVariables map[string]bool
+}
+
+// ValidationError is an invalid value of the configuration variable Variable.
+type ValidationError struct {
+	Variable string
+	Value    string
+	Message  string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid value %q of environment variable %s: %s", e.Value, e.Variable, e.Message)
+}
+
+// ValidationErrors are the validation errors of a Config.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// configFeatures are the environment variables enabling optional features and the names of the features.
+var configFeatures = []struct {
+	variable string
+	name     string
+}{
+	{sfxTracingEnabled, "tracing"},
+	{sfxTraceIDFromXRay, "trace_id_from_xray"},
+	{sfxTraceHonorUpstream, "trace_honor_upstream_sampling"},
+	{sfxTraceSampleErrors, "trace_sample_errors"},
+	{sfxXRayEventProperty, "xray_event_property"},
+	{sfxEventSourceDetailDimensions, "event_source_detail_dimensions"},
+	{sfxDebugPayloads, "debug_payloads"},
+}
+
+// ConfigFromEnv returns the Config the wrapper reads from the environment variables on cold start, e.g. to check the
+// configuration of a function in its tests. The error holds the ValidationErrors of the values that cannot be parsed,
+// which are left at their default, and of Config.Validate, or is nil if the configuration is valid.
+func ConfigFromEnv() (*Config, error) {
+	config, errs := configFromEnv(os.Getenv)
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+	if len(errs) > 0 {
+		return config, errs
+	}
+	return config, nil
+}
+
+// configFromEnv returns the Config of the environment variables read with getenv. Values that cannot be parsed are
+// returned as validation errors and leave the Config fields at their default.
+func configFromEnv(getenv func(string) string) (*Config, ValidationErrors) {
+	var errs ValidationErrors
+	config := &Config{
+		AuthToken:           getenv(sfxAuthToken),
+		AuthTokenSource:     strings.TrimSpace(getenv(sfxAuthTokenSource)),
+		SendTimeout:         sfxclient.DefaultTimeout,
+		MetricsTransport:    strings.ToLower(strings.TrimSpace(getenv(sfxMetricsTransport))),
+		TracesTransport:     strings.ToLower(strings.TrimSpace(getenv(sfxTracesTransport))),
+		DatapointFormat:     strings.ToLower(strings.TrimSpace(getenv(sfxDatapointFormat))),
+		TraceSampleRate:     1,
+		AuthTokenRefresh:    defaultTokenRefresh,
+		ForwarderBatchSize:  defaultForwarderBatchSize,
+		StatsdMaxPacketSize: defaultStatsdMaxPacketSize,
+		enabledVariables:    map[string]bool{},
+	}
+	endpoints, endpointErrs := resolveIngestEndpoints(getenv)
+	config.DatapointEndpoint, config.EventEndpoint, config.TraceEndpoint = endpoints.datapoint, endpoints.event, endpoints.trace
+	errs = append(errs, endpointErrs...)
+	parseFloat := func(variable string, f *float64) {
+		if value := strings.TrimSpace(getenv(variable)); value != "" {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				*f = parsed
+			} else {
+				errs = append(errs, &ValidationError{Variable: variable, Value: value, Message: "not a number"})
+			}
+		}
+	}
+	// parseDuration parses variable as a number of units.
+	parseDuration := func(variable string, unit time.Duration, d *time.Duration) {
+		f := float64(*d) / float64(unit)
+		parseFloat(variable, &f)
+		*d = time.Duration(f * float64(unit))
+	}
+	parseInt := func(variable string, i *int) {
+		if value := strings.TrimSpace(getenv(variable)); value != "" {
+			if parsed, err := strconv.Atoi(value); err == nil {
+				*i = parsed
+			} else {
+				errs = append(errs, &ValidationError{Variable: variable, Value: value, Message: "not an integer"})
+			}
+		}
+	}
+	parseDuration(sfxSendTimeoutSeconds, time.Second, &config.SendTimeout)
+	parseDuration(sfxAuthTokenRefreshSeconds, time.Second, &config.AuthTokenRefresh)
+	parseDuration(sfxTraceSlowMs, time.Millisecond, &config.TraceSlowThreshold)
+	parseFloat(sfxTraceSampleRate, &config.TraceSampleRate)
+	parseFloat(sfxTraceRateLimit, &config.TraceRateLimit)
+	parseInt(sfxForwarderBatchSize, &config.ForwarderBatchSize)
+	parseInt(sfxStatsdMaxPacketSize, &config.StatsdMaxPacketSize)
+	for _, feature := range configFeatures {
+		value := strings.TrimSpace(getenv(feature.variable))
+		if value == "" {
+			continue
+		}
+		if enabled, err := strconv.ParseBool(value); err != nil {
+			errs = append(errs, &ValidationError{Variable: feature.variable, Value: value, Message: "not a boolean"})
+		} else if enabled {
+			config.Features = append(config.Features, feature.name)
+			config.enabledVariables[feature.variable] = true
+		}
+	}
+	if value := strings.TrimSpace(getenv(sfxStrictConfig)); value != "" {
+		var err error
+		if config.Strict, err = strconv.ParseBool(value); err != nil {
+			errs = append(errs, &ValidationError{Variable: sfxStrictConfig, Value: value, Message: "not a boolean"})
+		}
+	}
+	return config, errs
+}
+
+// enabled returns whether the feature of the environment variable variable is enabled.
+func (c *Config) enabled(variable string) bool {
+	return c.enabledVariables[variable]
+}
+
+// Validate returns the ValidationErrors of c, or nil if c is valid.
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+	if c.AuthToken == "" && c.AuthTokenSource == "" {
+		errs = append(errs, &ValidationError{Variable: sfxAuthToken, Message: "no auth token and no auth token source " + sfxAuthTokenSource})
+	}
+	for _, e := range []struct {
+		variable string
+		url      string
+	}{
+		{sfxMetricsURL, c.DatapointEndpoint},
+		{sfxEventsURL, c.EventEndpoint},
+		{sfxTracesURL, c.TraceEndpoint},
+	} {
+		if err := validateURL(e.url); err != nil {
+			errs = append(errs, &ValidationError{Variable: e.variable, Value: e.url, Message: err.Error()})
+		}
+	}
+	if c.SendTimeout <= 0 {
+		errs = append(errs, &ValidationError{Variable: sfxSendTimeoutSeconds, Value: c.SendTimeout.String(), Message: "not positive"})
+	}
+	switch c.MetricsTransport {
+	case "", httpTransport, logTransport, otlpTransport, prometheusTransport, statsdTransport:
+	default:
+		errs = append(errs, &ValidationError{Variable: sfxMetricsTransport, Value: c.MetricsTransport, Message: "unknown transport"})
+	}
+	switch c.TracesTransport {
+	case "", httpTransport, otlpTransport:
+	default:
+		errs = append(errs, &ValidationError{Variable: sfxTracesTransport, Value: c.TracesTransport, Message: "unknown transport"})
+	}
+	switch c.DatapointFormat {
+	case "", protobufFormat, jsonFormat:
+	default:
+		errs = append(errs, &ValidationError{Variable: sfxDatapointFormat, Value: c.DatapointFormat, Message: "unknown format"})
+	}
+	if !c.validTraceSampleRate() {
+		errs = append(errs, &ValidationError{Variable: sfxTraceSampleRate, Value: strconv.FormatFloat(c.TraceSampleRate, 'f', -1, 64),
+			Message: "not between 0 and 1"})
+	}
+	if c.TraceSlowThreshold < 0 {
+		errs = append(errs, &ValidationError{Variable: sfxTraceSlowMs, Value: c.TraceSlowThreshold.String(), Message: "negative"})
+	}
+	if c.TraceRateLimit < 0 {
+		errs = append(errs, &ValidationError{Variable: sfxTraceRateLimit, Value: strconv.FormatFloat(c.TraceRateLimit, 'f', -1, 64),
+			Message: "negative"})
+	}
+	if c.AuthTokenRefresh < 0 {
+		errs = append(errs, &ValidationError{Variable: sfxAuthTokenRefreshSeconds, Value: c.AuthTokenRefresh.String(), Message: "negative"})
+	}
+	if c.ForwarderBatchSize <= 0 {
+		errs = append(errs, &ValidationError{Variable: sfxForwarderBatchSize, Value: strconv.Itoa(c.ForwarderBatchSize), Message: "not positive"})
+	}
+	if c.StatsdMaxPacketSize <= 0 {
+		errs = append(errs, &ValidationError{Variable: sfxStatsdMaxPacketSize, Value: strconv.Itoa(c.StatsdMaxPacketSize), Message: "not positive"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validTraceSampleRate returns whether the trace sample rate of c is between 0 and 1.
+func (c *Config) validTraceSampleRate() bool {
+	return c.TraceSampleRate >= 0 && c.TraceSampleRate <= 1
+}
+
+// logDiagnostics logs the configuration of the wrapper with the auth token redacted, along with the wrapper version and
+// the number of validation errors.
+func (c *Config) logDiagnostics(errs ValidationErrors) {
+	metricsTransport, tracesTransport, datapointFormat := c.MetricsTransport, c.TracesTransport, c.DatapointFormat
+	if metricsTransport == "" {
+		metricsTransport = httpTransport
+	}
+	if tracesTransport == "" {
+		tracesTransport = httpTransport
+	}
+	if datapointFormat == "" {
+		datapointFormat = protobufFormat
+	}
+	log.WithFields(log.Fields{
+		"wrapper":            name,
+		"wrapper_version":    version,
+		"auth_token":         redactToken(c.AuthToken),
+		"auth_token_source":  c.AuthTokenSource,
+		"datapoint_endpoint": c.DatapointEndpoint,
+		"event_endpoint":     c.EventEndpoint,
+		"trace_endpoint":     c.TraceEndpoint,
+		"send_timeout":       c.SendTimeout.String(),
+		"metrics_transport":  metricsTransport,
+		"traces_transport":   tracesTransport,
+		"datapoint_format":   datapointFormat,
+		"trace_sample_rate":  c.TraceSampleRate,
+		"features":           strings.Join(c.Features, ","),
+		"strict":             c.Strict,
+		"validation_errors":  len(errs),
+	}).Info("SignalFx Lambda wrapper configuration")
+}
+
+// redactToken returns token with all but its last 4 characters masked. Tokens of 8 characters or less are fully
+// masked.
+func redactToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	if len(token) <= 8 {
+		return "****"
+	}
+	return "****" + token[len(token)-4:]
+}
+
+// checkConfig logs the diagnostics and the validation errors of config, including the errors parseErrs of reading it.
+// It returns the validation errors if config is strict.
+func checkConfig(config *Config, parseErrs ValidationErrors) error {
+	errs := parseErrs
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err.(ValidationErrors)...)
+	}
+	config.logDiagnostics(errs)
+	for _, err := range errs {
+		log.Error(err)
+	}
+	if config.Strict && len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
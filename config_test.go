@@ -0,0 +1,171 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestConfigValidate(t *testing.T) {
+	var tests = []struct {
+		env       map[string]string
+		wantParse []string
+		want      []string
+	}{
+		{map[string]string{sfxAuthToken: "token"}, nil, nil},
+		{map[string]string{sfxAuthTokenSource: "ssm:/signalfx/token"}, nil, nil},
+		{nil, nil, []string{sfxAuthToken}},
+		{map[string]string{sfxAuthToken: "token", sfxSendTimeoutSeconds: "five"}, []string{sfxSendTimeoutSeconds}, nil},
+		{map[string]string{sfxAuthToken: "token", sfxSendTimeoutSeconds: "0"}, nil, []string{sfxSendTimeoutSeconds}},
+		{map[string]string{sfxAuthToken: "token", sfxMetricsURL: "localhost:9080"}, []string{sfxMetricsURL}, nil},
+		{map[string]string{sfxAuthToken: "token", sfxMetricsTransport: "carrier-pigeon", sfxTracesTransport: "log"}, nil,
+			[]string{sfxMetricsTransport, sfxTracesTransport}},
+		{map[string]string{sfxAuthToken: "token", sfxDatapointFormat: "xml"}, nil, []string{sfxDatapointFormat}},
+		{map[string]string{sfxAuthToken: "token", sfxTraceSampleRate: "1.5"}, nil, []string{sfxTraceSampleRate}},
+		{map[string]string{sfxAuthToken: "token", sfxTracingEnabled: "yes", sfxStrictConfig: "on"}, []string{sfxTracingEnabled, sfxStrictConfig}, nil},
+		{map[string]string{sfxAuthToken: "token", sfxTraceHonorUpstream: "maybe", sfxTraceSampleErrors: "sometimes"},
+			[]string{sfxTraceHonorUpstream, sfxTraceSampleErrors}, nil},
+		{map[string]string{sfxAuthToken: "token", sfxTraceSlowMs: "slow", sfxTraceRateLimit: "fast", sfxAuthTokenRefreshSeconds: "often"},
+			[]string{sfxAuthTokenRefreshSeconds, sfxTraceSlowMs, sfxTraceRateLimit}, nil},
+		{map[string]string{sfxAuthToken: "token", sfxTraceSlowMs: "-1", sfxTraceRateLimit: "-2", sfxAuthTokenRefreshSeconds: "-3"}, nil,
+			[]string{sfxTraceSlowMs, sfxTraceRateLimit, sfxAuthTokenRefreshSeconds}},
+		{map[string]string{sfxAuthToken: "token", sfxForwarderBatchSize: "ten", sfxStatsdMaxPacketSize: "1.5"},
+			[]string{sfxForwarderBatchSize, sfxStatsdMaxPacketSize}, nil},
+		{map[string]string{sfxAuthToken: "token", sfxForwarderBatchSize: "0", sfxStatsdMaxPacketSize: "-1"}, nil,
+			[]string{sfxForwarderBatchSize, sfxStatsdMaxPacketSize}},
+	}
+	for i, test := range tests {
+		config, parseErrs := configFromEnv(func(name string) string {
+			return test.env[name]
+		})
+		if got := validationVariables(parseErrs); !reflect.DeepEqual(got, test.wantParse) {
+			t.Errorf("test %d. want parse errors of %v got %v", i, test.wantParse, parseErrs)
+		}
+		var got []string
+		if err := config.Validate(); err != nil {
+			got = validationVariables(err.(ValidationErrors))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test %d. want validation errors of %v got %v", i, test.want, got)
+		}
+	}
+}
+
+func validationVariables(errs ValidationErrors) []string {
+	var variables []string
+	for _, err := range errs {
+		variables = append(variables, err.Variable)
+	}
+	return variables
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		sfxAuthToken:          "token",
+		sfxRealm:              "us1",
+		sfxSendTimeoutSeconds: "2.5",
+		sfxMetricsTransport:   " OTLP ",
+		sfxTraceSampleRate:    "0.25",
+		sfxTracingEnabled:     "true",
+		sfxDebugPayloads:      "true",
+		sfxTraceIDFromXRay:    "false",
+		sfxStrictConfig:       "true",
+		sfxTraceSlowMs:        "250",
+		sfxForwarderBatchSize: "50",
+	}
+	config, errs := configFromEnv(func(name string) string {
+		return env[name]
+	})
+	if len(errs) > 0 {
+		t.Fatalf("want no errors got %v", errs)
+	}
+	if config.DatapointEndpoint != "https://ingest.us1.signalfx.com/v2/datapoint" || config.SendTimeout != 2500*time.Millisecond ||
+		config.MetricsTransport != otlpTransport || config.TraceSampleRate != 0.25 || !config.Strict ||
+		config.TraceSlowThreshold != 250*time.Millisecond || config.ForwarderBatchSize != 50 || config.AuthTokenRefresh != defaultTokenRefresh {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if want := []string{"tracing", "debug_payloads"}; !reflect.DeepEqual(config.Features, want) {
+		t.Errorf("want features %v got %v", want, config.Features)
+	}
+	if !config.enabled(sfxTracingEnabled) || config.enabled(sfxTraceIDFromXRay) {
+		t.Errorf("unexpected enabled features %v", config.enabledVariables)
+	}
+}
+
+func TestRedactToken(t *testing.T) {
+	var tests = []struct {
+		token, want string
+	}{
+		{"", ""},
+		{"short", "****"},
+		{"12345678", "****"},
+		{"abcdefghijkl", "****ijkl"},
+	}
+	for _, test := range tests {
+		if got := redactToken(test.token); got != test.want {
+			t.Errorf("want %s got %s", test.want, got)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	for _, strict := range []bool{false, true} {
+		out.Reset()
+		config, _ := configFromEnv(func(name string) string {
+			return map[string]string{sfxAuthToken: "abcdefghijkl", sfxTraceSampleRate: "2"}[name]
+		})
+		config.Strict = strict
+		parseErrs := ValidationErrors{{Variable: sfxForwarderBatchSize, Value: "0", Message: "not positive"}}
+		err := checkConfig(config, parseErrs)
+		if strict {
+			if errs, ok := err.(ValidationErrors); !ok || len(errs) != 2 {
+				t.Errorf("want 2 validation errors in strict mode got %v", err)
+			}
+		} else if err != nil {
+			t.Errorf("want no error got %v", err)
+		}
+		logged := out.String()
+		if strings.Contains(logged, "abcdefghijkl") || !strings.Contains(logged, `auth_token="****ijkl"`) {
+			t.Errorf("want redacted token in %s", logged)
+		}
+		if !strings.Contains(logged, "validation_errors=2") || !strings.Contains(logged, sfxTraceSampleRate) ||
+			!strings.Contains(logged, sfxForwarderBatchSize) {
+			t.Errorf("want validation errors in %s", logged)
+		}
+	}
+}
+
+func TestConfigFromEnvValidation(t *testing.T) {
+	savedAuthToken, savedSampleRate, savedBatchSize := os.Getenv(sfxAuthToken), os.Getenv(sfxTraceSampleRate), os.Getenv(sfxForwarderBatchSize)
+	defer func() {
+		os.Setenv(sfxAuthToken, savedAuthToken)
+		os.Setenv(sfxTraceSampleRate, savedSampleRate)
+		os.Setenv(sfxForwarderBatchSize, savedBatchSize)
+	}()
+	os.Setenv(sfxAuthToken, "token")
+	os.Setenv(sfxTraceSampleRate, "0.5")
+	os.Setenv(sfxForwarderBatchSize, "")
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Errorf("want no error got %v", err)
+	}
+	if config.AuthToken != "token" || config.TraceSampleRate != 0.5 {
+		t.Errorf("want config of the environment variables got %+v", config)
+	}
+	os.Setenv(sfxTraceSampleRate, "2")
+	os.Setenv(sfxForwarderBatchSize, "many")
+	_, err = ConfigFromEnv()
+	errs, _ := err.(ValidationErrors)
+	if got, want := validationVariables(errs), []string{sfxForwarderBatchSize, sfxTraceSampleRate}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want parse and validation errors of %v got %v", want, err)
+	}
+}
@@ -0,0 +1,117 @@
+package sfxlambda
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/signalfx/golib/sfxclient"
+)
+
+var realmPattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// ingestEndpoints are the datapoint, event and trace endpoints of the SignalFx ingest API.
+type ingestEndpoints struct {
+	datapoint string
+	event     string
+	trace     string
+}
+
+// resolveIngestEndpoints returns the ingest endpoints configured by the environment variables read with getenv. The
+// endpoints are derived from SIGNALFX_INGEST_ENDPOINT or else SIGNALFX_REALM, and are overridden by the URLs of
+// SIGNALFX_METRICS_URL, SIGNALFX_EVENTS_URL and SIGNALFX_TRACES_URL. Invalid values are reported in the returned errors
+// and leave the endpoints they configure at their default.
+func resolveIngestEndpoints(getenv func(string) string) (ingestEndpoints, ValidationErrors) {
+	endpoints := ingestEndpoints{
+		datapoint: sfxclient.IngestEndpointV2,
+		event:     sfxclient.EventIngestEndpointV2,
+		trace:     sfxclient.TraceIngestEndpointV1,
+	}
+	var errs ValidationErrors
+	base := ""
+	if ingestEndpoint := strings.TrimSpace(getenv(sfxIngestEndpoint)); ingestEndpoint != "" {
+		if err := validateURL(ingestEndpoint); err == nil {
+			base = ingestEndpoint
+		} else {
+			errs = append(errs, &ValidationError{Variable: sfxIngestEndpoint, Value: ingestEndpoint, Message: err.Error()})
+		}
+	} else if realm := strings.TrimSpace(getenv(sfxRealm)); realm != "" {
+		if err := validateRealm(realm); err == nil {
+			base = realmIngestEndpoint(realm)
+		} else {
+			errs = append(errs, &ValidationError{Variable: sfxRealm, Value: realm, Message: err.Error()})
+		}
+	}
+	for _, e := range []struct {
+		endpoint *string
+		path     string
+		override string
+	}{
+		{&endpoints.datapoint, datapointPath, sfxMetricsURL},
+		{&endpoints.event, eventPath, sfxEventsURL},
+		{&endpoints.trace, tracePath, sfxTracesURL},
+	} {
+		if override := strings.TrimSpace(getenv(e.override)); override != "" {
+			if err := validateURL(override); err == nil {
+				*e.endpoint = override
+			} else {
+				errs = append(errs, &ValidationError{Variable: e.override, Value: override, Message: err.Error()})
+			}
+			continue
+		}
+		if base != "" {
+			// base is validated, resolving a relative path against it cannot fail.
+			*e.endpoint, _ = ingestEndpointURL(base, e.path)
+		}
+	}
+	return endpoints, errs
+}
+
+// ingestEndpointURL returns the URL of the ingest API path resolved against the ingest endpoint URL base. The path of
+// base is kept as a prefix whether it ends with a slash or not, e.g. for a proxy serving the ingest API under a path. An
+// ingest API path at the end of base, such as /v2/datapoint, is removed first.
+func ingestEndpointURL(base, path string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	prefix := strings.TrimSuffix(baseURL.Path, "/")
+	for _, p := range []string{datapointPath, eventPath, tracePath} {
+		prefix = strings.TrimSuffix(prefix, "/"+p)
+	}
+	baseURL.Path, baseURL.RawPath = prefix+"/", ""
+	pathURL, err := baseURL.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	return pathURL.String(), nil
+}
+
+// realmIngestEndpoint returns the ingest endpoint of realm.
+func realmIngestEndpoint(realm string) string {
+	return "https://ingest." + realm + ".signalfx.com"
+}
+
+// validateRealm returns an error if realm is not a realm name such as us1.
+func validateRealm(realm string) error {
+	if !realmPattern.MatchString(realm) {
+		return fmt.Errorf("realm %s is not made of lowercase letters and digits", realm)
+	}
+	return nil
+}
+
+// validateURL returns an error if s is not an absolute http or https URL.
+func validateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url %s is not http or https", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url %s has no host", s)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package sfxlambda
+
+import (
+	"testing"
+)
+
+func TestResolveIngestEndpoints(t *testing.T) {
+	var tests = []struct {
+		env      map[string]string
+		want     ingestEndpoints
+		wantErrs int
+	}{
+		{nil, ingestEndpoints{"https://ingest.signalfx.com/v2/datapoint", "https://ingest.signalfx.com/v2/event", "https://ingest.signalfx.com/v1/trace"}, 0},
+		{map[string]string{sfxRealm: "us1"},
+			ingestEndpoints{"https://ingest.us1.signalfx.com/v2/datapoint", "https://ingest.us1.signalfx.com/v2/event", "https://ingest.us1.signalfx.com/v1/trace"}, 0},
+		{map[string]string{sfxIngestEndpoint: "https://proxy:8080/signalfx", sfxRealm: "us1"},
+			ingestEndpoints{"https://proxy:8080/signalfx/v2/datapoint", "https://proxy:8080/signalfx/v2/event", "https://proxy:8080/signalfx/v1/trace"}, 0},
+		{map[string]string{sfxIngestEndpoint: "https://ingest.eu0.signalfx.com/v2/datapoint"},
+			ingestEndpoints{"https://ingest.eu0.signalfx.com/v2/datapoint", "https://ingest.eu0.signalfx.com/v2/event", "https://ingest.eu0.signalfx.com/v1/trace"}, 0},
+		{map[string]string{sfxRealm: "us1", sfxMetricsURL: "http://localhost:9080/v2/datapoint", sfxTracesURL: "http://localhost:9080/v2/trace"},
+			ingestEndpoints{"http://localhost:9080/v2/datapoint", "https://ingest.us1.signalfx.com/v2/event", "http://localhost:9080/v2/trace"}, 0},
+		{map[string]string{sfxRealm: "US 1", sfxEventsURL: "localhost:9080", sfxTracesURL: "http:///v1/trace"},
+			ingestEndpoints{"https://ingest.signalfx.com/v2/datapoint", "https://ingest.signalfx.com/v2/event", "https://ingest.signalfx.com/v1/trace"}, 3},
+	}
+	for i, test := range tests {
+		got, errs := resolveIngestEndpoints(func(name string) string {
+			return test.env[name]
+		})
+		if got != test.want {
+			t.Errorf("test %d. want %+v got %+v", i, test.want, got)
+		}
+		if len(errs) != test.wantErrs {
+			t.Errorf("test %d. want %d errors got %v", i, test.wantErrs, errs)
+		}
+	}
+}
+
+func TestIngestEndpointURL(t *testing.T) {
+	var tests = []struct {
+		base, path, want string
+	}{
+		{"https://ingest.us1.signalfx.com", datapointPath, "https://ingest.us1.signalfx.com/v2/datapoint"},
+		{"https://ingest.us1.signalfx.com/", eventPath, "https://ingest.us1.signalfx.com/v2/event"},
+		{"https://proxy/prefix", tracePath, "https://proxy/prefix/v1/trace"},
+		{"https://proxy/prefix/", datapointPath, "https://proxy/prefix/v2/datapoint"},
+		{"https://proxy/prefix/v1/trace", eventPath, "https://proxy/prefix/v2/event"},
+	}
+	for _, test := range tests {
+		if got, err := ingestEndpointURL(test.base, test.path); err != nil || got != test.want {
+			t.Errorf("want %s got %s %+v", test.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package sfxlambda
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Event sources reported as the event_source dimension.
+const (
+	sqsSource            = "sqs"
+	snsSource            = "sns"
+	s3Source             = "s3"
+	eventBridgeSource    = "eventbridge"
+	scheduleSource       = "schedule"
+	apiGatewaySource     = "apigateway"
+	albSource            = "alb"
+	kinesisSource        = "kinesis"
+	dynamoDBSource       = "dynamodb"
+	cloudWatchLogsSource = "cloudwatch_logs"
+	directSource         = "direct"
+)
+
+// eventPayload holds the fields of event source payloads used to derive event source metrics. Records of the different
+// event sources share the record struct, decoding only the fields the wrapper needs.
+type eventPayload struct {
+	Records    []eventRecord `json:"Records"`
+	DetailType string        `json:"detail-type"`
+	Source     string        `json:"source"`
+	AWSLogs    *struct {
+		Data string `json:"data"`
+	} `json:"awslogs"`
+	httpEvent
+}
+
+type eventRecord struct {
+	EventSource    string         `json:"eventSource"`
+	EventSourceARN string         `json:"eventSourceARN"`
+	EventID        string         `json:"eventID"`
+	Attributes     sqsAttributes  `json:"attributes"`
+	Kinesis        kinesisRecord  `json:"kinesis"`
+	DynamoDB       dynamoDBRecord `json:"dynamodb"`
+	S3             struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+	} `json:"s3"`
+	SNS struct {
+		TopicArn string `json:"TopicArn"`
+	} `json:"Sns"`
+}
+
+// parseEventPayload decodes the event source fields of payload. It returns nil if payload is not a JSON object.
+func parseEventPayload(payload []byte) *eventPayload {
+	var ev eventPayload
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil
+	}
+	return &ev
+}
+
+// eventSource returns the event source of ev, derived from the payload shape. Payloads of unknown shape are direct
+// invocations.
+func (ev *eventPayload) eventSource() string {
+	if ev == nil {
+		return directSource
+	}
+	if len(ev.Records) > 0 {
+		switch ev.Records[0].EventSource {
+		case "aws:sqs":
+			return sqsSource
+		case "aws:sns":
+			return snsSource
+		case "aws:s3":
+			return s3Source
+		case "aws:kinesis":
+			return kinesisSource
+		case "aws:dynamodb":
+			return dynamoDBSource
+		}
+	}
+	if _, _, ok := ev.httpRequest(); ok {
+		if ev.RequestContext.ELB != nil {
+			return albSource
+		}
+		return apiGatewaySource
+	}
+	if ev.AWSLogs != nil && ev.AWSLogs.Data != "" {
+		return cloudWatchLogsSource
+	}
+	if ev.DetailType != "" && ev.Source != "" {
+		if ev.Source == "aws.events" && ev.DetailType == "Scheduled Event" {
+			return scheduleSource
+		}
+		return eventBridgeSource
+	}
+	return directSource
+}
+
+// eventSourceDimensions returns the event_source dimension of ev and, if detailed, the source specific dimensions
+// bucket_name of S3 events, topic_name of SNS events and detail_type and source of EventBridge events.
+func eventSourceDimensions(ev *eventPayload, detailed bool) map[string]string {
+	source := ev.eventSource()
+	dims := map[string]string{"event_source": source}
+	if !detailed {
+		return dims
+	}
+	switch source {
+	case s3Source:
+		if name := ev.Records[0].S3.Bucket.Name; name != "" {
+			dims["bucket_name"] = name
+		}
+	case snsSource:
+		if arn := ev.Records[0].SNS.TopicArn; arn != "" {
+			dims["topic_name"] = arn[strings.LastIndex(arn, ":")+1:]
+		}
+	case eventBridgeSource:
+		dims["detail_type"] = ev.DetailType
+		dims["source"] = ev.Source
+	}
+	return dims
+}
+
+// eventDatapoints returns the event source metrics of the invocation event ev received at now.
+func eventDatapoints(ev *eventPayload, now time.Time) []*datapoint.Datapoint {
+	switch ev.eventSource() {
+	case sqsSource:
+		return sqsDatapoints(ev.Records, now)
+	case kinesisSource, dynamoDBSource:
+		return streamDatapoints(ev.Records, now)
+	}
+	return nil
+}
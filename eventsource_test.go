@@ -0,0 +1,44 @@
+package sfxlambda
+
+import (
+	"testing"
+)
+
+func TestEventSourceDimensions(t *testing.T) {
+	var tests = []struct {
+		payload string
+		want    map[string]string
+	}{
+		{sqsEvent, map[string]string{"event_source": "sqs"}},
+		{kinesisEvent, map[string]string{"event_source": "kinesis"}},
+		{dynamoDBEvent, map[string]string{"event_source": "dynamodb"}},
+		{`{"Records":[{"eventSource":"aws:s3","s3":{"bucket":{"name":"my-bucket"}}}]}`,
+			map[string]string{"event_source": "s3", "bucket_name": "my-bucket"}},
+		{`{"Records":[{"EventSource":"aws:sns","Sns":{"TopicArn":"arn:aws:sns:us-east-2:123456789012:my-topic"}}]}`,
+			map[string]string{"event_source": "sns", "topic_name": "my-topic"}},
+		{`{"version":"0","detail-type":"Order Created","source":"com.example.orders","detail":{}}`,
+			map[string]string{"event_source": "eventbridge", "detail_type": "Order Created", "source": "com.example.orders"}},
+		{`{"version":"0","detail-type":"Scheduled Event","source":"aws.events","detail":{}}`,
+			map[string]string{"event_source": "schedule"}},
+		{`{"resource":"/","httpMethod":"GET"}`, map[string]string{"event_source": "apigateway"}},
+		{`{"requestContext":{"elb":{}},"httpMethod":"GET","path":"/"}`, map[string]string{"event_source": "alb"}},
+		{`{"awslogs":{"data":"H4sIAAAAAAAAAA=="}}`, map[string]string{"event_source": "cloudwatch_logs"}},
+		{`{"key":"value"}`, map[string]string{"event_source": "direct"}},
+		{`""`, map[string]string{"event_source": "direct"}},
+	}
+	for _, test := range tests {
+		got := eventSourceDimensions(parseEventPayload([]byte(test.payload)), true)
+		if len(got) != len(test.want) {
+			t.Errorf("payload %s. want %+v got %+v", test.payload, test.want, got)
+			continue
+		}
+		for k, v := range test.want {
+			if got[k] != v {
+				t.Errorf("payload %s. want %s %s got %s", test.payload, k, v, got[k])
+			}
+		}
+		if got := eventSourceDimensions(parseEventPayload([]byte(test.payload)), false); len(got) != 1 || got["event_source"] != test.want["event_source"] {
+			t.Errorf("payload %s. want only event_source dimension got %+v", test.payload, got)
+		}
+	}
+}
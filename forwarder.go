@@ -0,0 +1,137 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+	"github.com/signalfx/lambda-go/logformat"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultForwarderBatchSize = 100
+
+// logForwarder is a lambda.Handler implementation for a CloudWatch Logs subscription target that sends the metric log
+// lines written with SIGNALFX_METRICS_TRANSPORT=log to SignalFx.
+type logForwarder struct {
+	sink      sfxclient.Sink
+	batchSize int
+}
+
+// cloudWatchLogsEvent is the event of a CloudWatch Logs subscription.
+type cloudWatchLogsEvent struct {
+	AWSLogs struct {
+		Data string `json:"data"`
+	} `json:"awslogs"`
+}
+
+// cloudWatchLogsData is the decoded data of a CloudWatch Logs subscription event.
+type cloudWatchLogsData struct {
+	MessageType string `json:"messageType"`
+	LogGroup    string `json:"logGroup"`
+	LogStream   string `json:"logStream"`
+	LogEvents   []struct {
+		ID        string `json:"id"`
+		Timestamp int64  `json:"timestamp"`
+		Message   string `json:"message"`
+	} `json:"logEvents"`
+}
+
+// NewLogForwarder creates a lambda.Handler to deploy as a CloudWatch Logs subscription target of functions writing
+// metric log lines (SIGNALFX_METRICS_TRANSPORT=log). It decodes the subscription events, parses the metric log lines
+// into datapoints with their original dimensions and timestamps, falling back to the log event timestamps, and sends
+// them to SignalFx in batches of SIGNALFX_FORWARDER_BATCH_SIZE datapoints. It also sends the forwarder.datapoints and
+// forwarder.parse_errors counters dimensioned by log_group. Wrap the handler with NewHandlerWrapper for the function metrics of the forwarder itself.
+func NewLogForwarder() lambda.Handler {
+	return &logForwarder{sink: handlerFuncWrapperClient, batchSize: forwarderBatchSize}
+}
+
+// Invoke is logForwarder's lambda.Handler implementation.
+func (f *logForwarder) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	data, err := decodeCloudWatchLogsEvent(payload)
+	if err != nil {
+		return nil, err
+	}
+	if data.MessageType != "DATA_MESSAGE" {
+		return nil, nil
+	}
+	now := time.Now()
+	var dps []*datapoint.Datapoint
+	var parseErrors int64
+	for _, e := range data.LogEvents {
+		for _, line := range strings.Split(e.Message, "\n") {
+			if !logformat.IsMetricLine(line) {
+				continue
+			}
+			// Runtimes may prefix log lines, e.g. with a timestamp and the request ID.
+			idx := strings.Index(line, "{")
+			if idx < 0 {
+				log.Errorf("invalid metric log line %s. no JSON object", line)
+				parseErrors++
+				continue
+			}
+			dp, err := logformat.Parse(line[idx:])
+			if err != nil {
+				log.Error(err)
+				parseErrors++
+				continue
+			}
+			if dp.Timestamp.IsZero() {
+				dp.Timestamp = time.Unix(0, e.Timestamp*int64(time.Millisecond))
+			}
+			dps = append(dps, dp)
+		}
+	}
+	dims := map[string]string{"log_group": data.LogGroup}
+	dps = append(dps,
+		&datapoint.Datapoint{Metric: "forwarder.datapoints", Value: datapoint.NewIntValue(int64(len(dps))), MetricType: datapoint.Counter, Dimensions: dims, Timestamp: now},
+		&datapoint.Datapoint{Metric: "forwarder.parse_errors", Value: datapoint.NewIntValue(parseErrors), MetricType: datapoint.Counter, Dimensions: dims, Timestamp: now})
+	var errs []string
+	for start := 0; start < len(dps); start += f.batchSize {
+		end := start + f.batchSize
+		if end > len(dps) {
+			end = len(dps)
+		}
+		if err := f.sink.AddDatapoints(ctx, dps[start:end]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("error forwarding datapoints to SignalFx. %s", strings.Join(errs, "\n"))
+}
+
+// decodeCloudWatchLogsEvent decodes the base64 encoded and gzip compressed data of a CloudWatch Logs subscription event.
+func decodeCloudWatchLogsEvent(payload []byte) (*cloudWatchLogsData, error) {
+	var event cloudWatchLogsEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid cloudwatch logs event. %+v", err)
+	}
+	compressed, err := base64.StdEncoding.DecodeString(event.AWSLogs.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 cloudwatch logs data. %+v", err)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip cloudwatch logs data. %+v", err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip cloudwatch logs data. %+v", err)
+	}
+	var data cloudWatchLogsData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("invalid cloudwatch logs data. %+v", err)
+	}
+	return &data, nil
+}
@@ -0,0 +1,100 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/lambda-go/logformat"
+)
+
+type batchRecorder struct {
+	batches [][]*datapoint.Datapoint
+}
+
+func (r *batchRecorder) AddDatapoints(_ context.Context, dps []*datapoint.Datapoint) error {
+	r.batches = append(r.batches, dps)
+	return nil
+}
+
+func cloudWatchLogsPayload(t *testing.T, messages ...string) []byte {
+	type logEvent struct {
+		ID        string `json:"id"`
+		Timestamp int64  `json:"timestamp"`
+		Message   string `json:"message"`
+	}
+	data := map[string]interface{}{
+		"messageType": "DATA_MESSAGE",
+		"logGroup":    "/aws/lambda/my-function",
+		"logStream":   "2019/01/01/[$LATEST]abc",
+	}
+	var events []logEvent
+	for i, m := range messages {
+		events = append(events, logEvent{ID: string(rune('a' + i)), Timestamp: 1545082649183, Message: m})
+	}
+	data["logEvents"] = events
+	b, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"awslogs": map[string]string{"data": base64.StdEncoding.EncodeToString(buf.Bytes())}})
+	return payload
+}
+
+func TestLogForwarder(t *testing.T) {
+	timestamp := time.Unix(1545082649, 183*int64(time.Millisecond))
+	line, err := logformat.Encode(&datapoint.Datapoint{Metric: "function.invocations", Value: datapoint.NewIntValue(1),
+		MetricType: datapoint.Counter, Dimensions: map[string]string{"aws_function_name": "my-function"}, Timestamp: timestamp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &batchRecorder{}
+	forwarder := &logForwarder{sink: recorder, batchSize: 2}
+	payload := cloudWatchLogsPayload(t,
+		"START RequestId: 8f507cfc-xmpl-4697-b07a-ac58fc914c95 Version: $LATEST\n",
+		`{"format":"signalfx-metric-v1","metric":"function.invocations","type":"counter","value":1,"dimensions":{"aws_function_name":"my-function"}}`+"\n",
+		"2019-01-01T00:00:00.000Z\t8f507cfc-xmpl-4697-b07a-ac58fc914c95\t"+string(line)+"\n",
+		`{"format":"signalfx-metric-v1","metric":"broken","type":"histogram","value":1,"timestamp":1}`+"\n",
+		`truncated "format":"signalfx-metric-v1","metric":"function.invocations"}`,
+	)
+	if _, err := forwarder.Invoke(context.TODO(), payload); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if len(recorder.batches) != 2 {
+		t.Fatalf("want 2 batches got %d", len(recorder.batches))
+	}
+	var dps []*datapoint.Datapoint
+	for _, batch := range recorder.batches {
+		dps = append(dps, batch...)
+	}
+	if len(dps) != 4 {
+		t.Fatalf("want 4 datapoints got %d", len(dps))
+	}
+	for _, dp := range dps[:2] {
+		if dp.Metric != "function.invocations" || !dp.Timestamp.Equal(timestamp) || dp.Dimensions["aws_function_name"] != "my-function" {
+			t.Errorf("want forwarded function.invocations datapoint got %+v", dp)
+		}
+	}
+	want := map[string]int64{"forwarder.datapoints": 2, "forwarder.parse_errors": 2}
+	for _, dp := range dps[2:] {
+		if got := dp.Value.(datapoint.IntValue).Int(); got != want[dp.Metric] || dp.Dimensions["log_group"] != "/aws/lambda/my-function" {
+			t.Errorf("want %s %d with log_group dimension got %d %+v", dp.Metric, want[dp.Metric], got, dp.Dimensions)
+		}
+	}
+	if _, err := forwarder.Invoke(context.TODO(), []byte(`{"awslogs":{"data":"not base64"}}`)); err == nil {
+		t.Errorf("want error for invalid data")
+	}
+}
@@ -0,0 +1,83 @@
+package sfxlambda
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+// httpEvent holds the fields of API Gateway REST API, API Gateway HTTP API (payload format version 2.0) and ALB
+// events used to derive HTTP request metrics.
+type httpEvent struct {
+	HTTPMethod     string            `json:"httpMethod"`
+	Resource       string            `json:"resource"`
+	Path           string            `json:"path"`
+	Version        string            `json:"version"`
+	RouteKey       string            `json:"routeKey"`
+	Headers        map[string]string `json:"headers"`
+	RequestContext struct {
+		ELB *struct {
+			TargetGroupArn string `json:"targetGroupArn"`
+		} `json:"elb"`
+		HTTP struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// httpRequest returns the method and route of ev, and false if ev is not an HTTP event. The route is the resource of
+// API Gateway REST API events and the route key of API Gateway HTTP API events. ALB events have no route, as their
+// raw request path, e.g. /users/123, would make an unbounded number of metric time series.
+func (ev *httpEvent) httpRequest() (method, route string, ok bool) {
+	switch {
+	case ev.Version == "2.0" && ev.RouteKey != "" && ev.RequestContext.HTTP.Method != "":
+		route = ev.RouteKey
+		// Route keys of HTTP APIs include the method, e.g. GET /pets/{id}.
+		if i := strings.Index(route, " "); i >= 0 {
+			route = route[i+1:]
+		}
+		return ev.RequestContext.HTTP.Method, route, true
+	case ev.RequestContext.ELB != nil && ev.HTTPMethod != "":
+		return ev.HTTPMethod, "", true
+	case ev.HTTPMethod != "" && ev.Resource != "":
+		return ev.HTTPMethod, ev.Resource, true
+	}
+	return "", "", false
+}
+
+// httpDatapoints returns the http.requests counter and the http.duration gauge of an HTTP event invocation, dimensioned
+// by method, route if any and, for http.requests, the status_code of the handler response. Failed invocations have the status
+// code 502 returned by API Gateway and ALB.
+func httpDatapoints(ev *eventPayload, response []byte, err error, elapsed time.Duration) []*datapoint.Datapoint {
+	if ev == nil {
+		return nil
+	}
+	method, route, ok := ev.httpRequest()
+	if !ok {
+		return nil
+	}
+	statusCode := "502"
+	if err == nil {
+		var r struct {
+			StatusCode *int `json:"statusCode"`
+		}
+		if json.Unmarshal(response, &r) == nil && r.StatusCode != nil {
+			statusCode = strconv.Itoa(*r.StatusCode)
+		} else if ev.Version == "2.0" {
+			// HTTP APIs treat responses without status code as the body of a 200 response.
+			statusCode = "200"
+		}
+	}
+	dims := map[string]string{"method": method}
+	if route != "" {
+		dims["route"] = route
+	}
+	return []*datapoint.Datapoint{
+		{Metric: "http.requests", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter,
+			Dimensions: datapoint.AddMaps(dims, map[string]string{"status_code": statusCode})},
+		{Metric: "http.duration", Value: datapoint.NewIntValue(Milliseconds(elapsed)), MetricType: datapoint.Gauge, Dimensions: dims},
+	}
+}
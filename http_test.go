@@ -0,0 +1,57 @@
+package sfxlambda
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHTTPDatapoints(t *testing.T) {
+	var tests = []struct {
+		payload  string
+		response string
+		err      error
+		want     map[string]string
+	}{
+		{`{"resource":"/pets/{id}","path":"/pets/1","httpMethod":"GET","requestContext":{"resourcePath":"/pets/{id}","httpMethod":"GET","stage":"prod"}}`,
+			`{"statusCode":404,"body":""}`,
+			nil,
+			map[string]string{"method": "GET", "route": "/pets/{id}", "status_code": "404"},
+		},
+		{`{"version":"2.0","routeKey":"POST /pets","rawPath":"/pets","requestContext":{"http":{"method":"POST","path":"/pets"}}}`,
+			`"created"`,
+			nil,
+			map[string]string{"method": "POST", "route": "/pets", "status_code": "200"},
+		},
+		{`{"requestContext":{"elb":{"targetGroupArn":"arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/lambda/abc"}},"httpMethod":"GET","path":"/users/123"}`,
+			`{"statusCode":200}`,
+			nil,
+			map[string]string{"method": "GET", "route": "", "status_code": "200"},
+		},
+		{`{"resource":"/pets","path":"/pets","httpMethod":"GET"}`,
+			``,
+			errors.New("failed"),
+			map[string]string{"method": "GET", "route": "/pets", "status_code": "502"},
+		},
+	}
+	for _, test := range tests {
+		dps := httpDatapoints(parseEventPayload([]byte(test.payload)), []byte(test.response), test.err, time.Millisecond)
+		if len(dps) != 2 {
+			t.Fatalf("want 2 datapoints got %d", len(dps))
+		}
+		requests := dps[0]
+		if requests.Metric != "http.requests" {
+			t.Errorf("want http.requests got %s", requests.Metric)
+		}
+		for k, v := range test.want {
+			if got, ok := requests.Dimensions[k]; got != v || ok != (v != "") {
+				t.Errorf("want dimension %s %q got %q", k, v, got)
+			}
+		}
+	}
+	for _, payload := range []string{`{}`, `{"httpMethod":"GET"}`, `{"version":"0","detail-type":"Scheduled Event"}`, sqsEvent} {
+		if dps := httpDatapoints(parseEventPayload([]byte(payload)), nil, nil, time.Millisecond); len(dps) != 0 {
+			t.Errorf("want no datapoints for payload %s got %+v", payload, dps)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// Datapoint formats of SIGNALFX_DATAPOINT_FORMAT.
+const (
+	protobufFormat = "protobuf"
+	jsonFormat     = "json"
+)
+
+// jsonDatapoint is a datapoint of the SignalFx JSON /v2/datapoint format.
+type jsonDatapoint struct {
+	Metric     string            `json:"metric"`
+	Value      interface{}       `json:"value"`
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+	Timestamp  int64             `json:"timestamp,omitempty"`
+}
+
+// jsonDatapointSink is a sfxclient.Sink sending datapoints to the datapoint endpoint of sink in the SignalFx JSON
+// /v2/datapoint format instead of protobuf, with the auth token, user agent and additional headers of sink. When debug
+// is set the JSON payload of every request is logged.
+type jsonDatapointSink struct {
+	sink  *sfxclient.HTTPSink
+	debug bool
+}
+
+// AddDatapoints is jsonDatapointSink's sfxclient.Sink implementation.
+func (s *jsonDatapointSink) AddDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
+	if len(dps) == 0 || s.sink.DatapointEndpoint == "" {
+		return nil
+	}
+	body, err := encodeJSONDatapoints(dps)
+	if err != nil {
+		return err
+	}
+	if s.debug {
+		log.Infof("sending datapoint payload to %s: %s", s.sink.DatapointEndpoint, body)
+	}
+	req, err := http.NewRequest("POST", s.sink.DatapointEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create datapoint request to %s. %+v", s.sink.DatapointEndpoint, err)
+	}
+	req = req.WithContext(ctx)
+	for k, v := range s.sink.AdditionalHeaders {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(sfxclient.TokenHeaderName, s.sink.AuthToken)
+	req.Header.Set("User-Agent", s.sink.UserAgent)
+	resp, err := s.sink.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send datapoint request. %+v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if s.debug {
+		log.Infof("datapoint response status code %d: %s", resp.StatusCode, respBody)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return sfxclient.SFXAPIError{StatusCode: resp.StatusCode, ResponseBody: string(respBody)}
+	}
+	var ok string
+	if err := json.Unmarshal(respBody, &ok); err != nil || ok != "OK" {
+		return fmt.Errorf("invalid datapoint response body %s", respBody)
+	}
+	return nil
+}
+
+// encodeJSONDatapoints returns the JSON /v2/datapoint body of dps, i.e. the datapoints grouped into the gauge, counter
+// and cumulative_counter arrays. Dimension keys are sanitized like the protobuf encoding of sfxclient.HTTPSink does.
+func encodeJSONDatapoints(dps []*datapoint.Datapoint) ([]byte, error) {
+	body := map[string][]jsonDatapoint{}
+	for _, dp := range dps {
+		var value interface{}
+		switch v := dp.Value.(type) {
+		case datapoint.IntValue:
+			value = v.Int()
+		case datapoint.FloatValue:
+			value = v.Float()
+		default:
+			return nil, fmt.Errorf("unsupported value %v of metric %s", dp.Value, dp.Metric)
+		}
+		metricType := "gauge"
+		switch dp.MetricType {
+		case datapoint.Count:
+			metricType = "counter"
+		case datapoint.Counter:
+			metricType = "cumulative_counter"
+		}
+		jsonDP := jsonDatapoint{Metric: dp.Metric, Value: value}
+		if len(dp.Dimensions) > 0 {
+			jsonDP.Dimensions = make(map[string]string, len(dp.Dimensions))
+			for k, v := range dp.Dimensions {
+				jsonDP.Dimensions[jsonDimensionKey(k)] = v
+			}
+		}
+		if !dp.Timestamp.IsZero() {
+			jsonDP.Timestamp = dp.Timestamp.UnixNano() / 1e6
+		}
+		body[metricType] = append(body[metricType], jsonDP)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode datapoints into json. %+v", err)
+	}
+	return b, nil
+}
+
+// jsonDimensionKey replaces the characters of k other than letters, digits, underscores and dashes with underscores.
+func jsonDimensionKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || unicode.IsLetter(r) || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, k)
+}
@@ -0,0 +1,91 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestJSONDatapointSink(t *testing.T) {
+	var body []byte
+	var header http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`"OK"`))
+	}))
+	defer receiver.Close()
+	httpSink := sfxclient.NewHTTPSink()
+	httpSink.AuthToken = "token"
+	httpSink.DatapointEndpoint = receiver.URL + "/v2/datapoint"
+	sink := &jsonDatapointSink{sink: httpSink, debug: true}
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	timestamp := time.Unix(1545082649, 183*int64(time.Millisecond))
+	dps := []*datapoint.Datapoint{
+		{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter,
+			Dimensions: map[string]string{"aws_function_name": "my-function", "http.method": "GET"}, Timestamp: timestamp},
+		{Metric: "db.query.count", Value: datapoint.NewIntValue(2), MetricType: datapoint.Count, Timestamp: timestamp},
+		{Metric: "sqs.message_age", Value: datapoint.NewFloatValue(2.5), MetricType: datapoint.Gauge, Timestamp: timestamp},
+	}
+	if err := sink.AddDatapoints(context.TODO(), dps); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if header.Get("Content-Type") != "application/json" || header.Get(sfxclient.TokenHeaderName) != "token" {
+		t.Errorf("want json content type and auth token got %v", header)
+	}
+	want := `{"counter":[{"metric":"db.query.count","value":2,"timestamp":1545082649183}],` +
+		`"cumulative_counter":[{"metric":"function.invocations","value":1,"dimensions":{"aws_function_name":"my-function","http_method":"GET"},"timestamp":1545082649183}],` +
+		`"gauge":[{"metric":"sqs.message_age","value":2.5,"timestamp":1545082649183}]}`
+	if string(body) != want {
+		t.Errorf("want body %s got %s", want, body)
+	}
+	if !strings.Contains(out.String(), strings.Replace(want, `"`, `\"`, -1)) {
+		t.Errorf("want payload logged in debug mode got %s", out.String())
+	}
+}
+
+func TestJSONDatapointSinkErrors(t *testing.T) {
+	var tests = []struct {
+		status int
+		body   string
+	}{
+		{http.StatusUnauthorized, `"Unauthorized"`},
+		{http.StatusOK, `"NOT OK"`},
+	}
+	for _, test := range tests {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+		httpSink := sfxclient.NewHTTPSink()
+		httpSink.DatapointEndpoint = receiver.URL
+		sink := &jsonDatapointSink{sink: httpSink}
+		dps := []*datapoint.Datapoint{{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter}}
+		if err := sink.AddDatapoints(context.TODO(), dps); err == nil {
+			t.Errorf("want error for status %d body %s", test.status, test.body)
+		}
+		receiver.Close()
+	}
+	if _, err := encodeJSONDatapoints([]*datapoint.Datapoint{{Metric: "bad", Value: datapoint.NewStringValue("x")}}); err == nil {
+		t.Errorf("want error for string value")
+	}
+	var decoded map[string]interface{}
+	if b, _ := encodeJSONDatapoints(nil); json.Unmarshal(b, &decoded) != nil || len(decoded) != 0 {
+		t.Errorf("want empty object for no datapoints got %s", b)
+	}
+}
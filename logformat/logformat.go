@@ -0,0 +1,123 @@
+// Package logformat encodes datapoints as metric log lines and parses them back. A metric log line is a JSON object on
+// a single line with the fields
+//
+//	format      the literal value "signalfx-metric-v1"
+//	metric      the metric name
+//	type        gauge, counter or cumulative_counter
+//	value       the integer or floating point value
+//	dimensions  the dimensions as an object of strings, omitted if empty
+//	timestamp   the milliseconds since the Unix epoch
+//
+// e.g. {"format":"signalfx-metric-v1","metric":"function.invocations","type":"cumulative_counter","value":1,"dimensions":{"aws_function_name":"my-function"},"timestamp":1545082649183}
+package logformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+// Format is the value of the format field of metric log lines.
+const Format = "signalfx-metric-v1"
+
+// Metric types of metric log lines. They follow the SignalFx metric types that the datapoint.MetricType values map to.
+const (
+	Gauge             = "gauge"
+	Counter           = "counter"
+	CumulativeCounter = "cumulative_counter"
+)
+
+type line struct {
+	Format     string            `json:"format"`
+	Metric     string            `json:"metric"`
+	Type       string            `json:"type"`
+	Value      json.Number       `json:"value"`
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+	Timestamp  int64             `json:"timestamp"`
+}
+
+// Encode returns the metric log line of dp without trailing newline.
+func Encode(dp *datapoint.Datapoint) ([]byte, error) {
+	l := line{
+		Format:     Format,
+		Metric:     dp.Metric,
+		Dimensions: dp.Dimensions,
+		Timestamp:  dp.Timestamp.UnixNano() / int64(time.Millisecond),
+	}
+	switch dp.MetricType {
+	case datapoint.Count:
+		l.Type = Counter
+	case datapoint.Counter:
+		l.Type = CumulativeCounter
+	default:
+		l.Type = Gauge
+	}
+	switch v := dp.Value.(type) {
+	case datapoint.IntValue:
+		l.Value = json.Number(fmt.Sprintf("%d", v.Int()))
+	case datapoint.FloatValue:
+		b, err := json.Marshal(v.Float())
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of datapoint %s. %+v", dp.Metric, err)
+		}
+		l.Value = json.Number(b)
+	default:
+		return nil, fmt.Errorf("unsupported value type %T of datapoint %s", dp.Value, dp.Metric)
+	}
+	return json.Marshal(l)
+}
+
+// IsMetricLine reports whether s looks like a metric log line. It is a cheap check for filtering log messages before
+// parsing them with Parse.
+func IsMetricLine(s string) bool {
+	return strings.Contains(s, `"format":"`+Format+`"`)
+}
+
+// Parse parses the metric log line s into a datapoint. Leading and trailing white space is ignored. The timestamp of the
+// datapoint is the zero time if the line has no timestamp.
+func Parse(s string) (*datapoint.Datapoint, error) {
+	var l line
+	decoder := json.NewDecoder(strings.NewReader(strings.TrimSpace(s)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&l); err != nil {
+		return nil, fmt.Errorf("invalid metric log line %s. %+v", s, err)
+	}
+	if l.Format != Format {
+		return nil, fmt.Errorf("invalid metric log line %s. want format %s got %s", s, Format, l.Format)
+	}
+	if l.Metric == "" {
+		return nil, fmt.Errorf("invalid metric log line %s. no metric", s)
+	}
+	dp := &datapoint.Datapoint{
+		Metric:     l.Metric,
+		Dimensions: l.Dimensions,
+	}
+	if l.Timestamp != 0 {
+		dp.Timestamp = time.Unix(0, l.Timestamp*int64(time.Millisecond))
+	}
+	if dp.Dimensions == nil {
+		dp.Dimensions = map[string]string{}
+	}
+	switch l.Type {
+	case Gauge:
+		dp.MetricType = datapoint.Gauge
+	case Counter:
+		dp.MetricType = datapoint.Count
+	case CumulativeCounter:
+		dp.MetricType = datapoint.Counter
+	default:
+		return nil, fmt.Errorf("invalid metric log line %s. unknown type %s", s, l.Type)
+	}
+	if i, err := l.Value.Int64(); err == nil && !bytes.ContainsAny([]byte(l.Value), ".eE") {
+		dp.Value = datapoint.NewIntValue(i)
+	} else if f, err := l.Value.Float64(); err == nil {
+		dp.Value = datapoint.NewFloatValue(f)
+	} else {
+		return nil, fmt.Errorf("invalid metric log line %s. invalid value %s", s, l.Value)
+	}
+	return dp, nil
+}
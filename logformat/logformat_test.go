@@ -0,0 +1,63 @@
+package logformat
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestRoundTrip(t *testing.T) {
+	timestamp := time.Unix(1545082649, 183*int64(time.Millisecond))
+	var tests = []*datapoint.Datapoint{
+		{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter,
+			Dimensions: map[string]string{"aws_function_name": "my-function"}, Timestamp: timestamp},
+		{Metric: "function.duration", Value: datapoint.NewIntValue(42), MetricType: datapoint.Gauge,
+			Dimensions: map[string]string{}, Timestamp: timestamp},
+		{Metric: "ratio", Value: datapoint.NewFloatValue(0.25), MetricType: datapoint.Count,
+			Dimensions: map[string]string{"a": "b"}, Timestamp: timestamp},
+	}
+	for _, dp := range tests {
+		b, err := Encode(dp)
+		if err != nil {
+			t.Fatalf("want no error got %+v", err)
+		}
+		if !IsMetricLine(string(b)) {
+			t.Errorf("want metric line got %s", b)
+		}
+		got, err := Parse(string(b) + "\n")
+		if err != nil {
+			t.Fatalf("want no error got %+v", err)
+		}
+		if got.Metric != dp.Metric || got.MetricType != dp.MetricType || got.Value.String() != dp.Value.String() ||
+			!got.Timestamp.Equal(dp.Timestamp) || !reflect.DeepEqual(got.Dimensions, dp.Dimensions) {
+			t.Errorf("want %+v got %+v", dp, got)
+		}
+	}
+}
+
+func TestParseInvalidLines(t *testing.T) {
+	for _, s := range []string{
+		``,
+		`START RequestId: 8f507cfc-xmpl-4697-b07a-ac58fc914c95 Version: $LATEST`,
+		`{"metric":"m","type":"gauge","value":1,"timestamp":1}`,
+		`{"format":"signalfx-metric-v1","type":"gauge","value":1,"timestamp":1}`,
+		`{"format":"signalfx-metric-v1","metric":"m","type":"histogram","value":1,"timestamp":1}`,
+		`{"format":"signalfx-metric-v1","metric":"m","type":"gauge","value":"x","timestamp":1}`,
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("want error for line %s", s)
+		}
+	}
+}
+
+func TestParseWithoutTimestamp(t *testing.T) {
+	dp, err := Parse(`{"format":"signalfx-metric-v1","metric":"m","type":"gauge","value":1}`)
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if !dp.Timestamp.IsZero() {
+		t.Errorf("want zero timestamp got %v", dp.Timestamp)
+	}
+}
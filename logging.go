@@ -0,0 +1,113 @@
+package sfxlambda
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	log "github.com/sirupsen/logrus"
+)
+
+// Log entry fields added for request correlation.
+const (
+	requestIDField       = "aws_request_id"
+	functionNameField    = "aws_function_name"
+	functionVersionField = "aws_function_version"
+	traceIDField         = "trace_id"
+	spanIDField          = "span_id"
+)
+
+var (
+	invocationLogFieldsMu sync.Mutex
+	invocationLogFields   = map[context.Context]log.Fields{}
+)
+
+// LogFields returns the request correlation fields of the invocation in ctx: aws_request_id, aws_function_name,
+// aws_function_version, aws_xray_trace_id and, when tracing is enabled, trace_id and span_id of the span in ctx.
+func LogFields(ctx context.Context) log.Fields {
+	fields := log.Fields{
+		functionNameField:    lambdacontext.FunctionName,
+		functionVersionField: lambdacontext.FunctionVersion,
+	}
+	if lambdaContext, ok := lambdacontext.FromContext(ctx); ok {
+		fields[requestIDField] = lambdaContext.AwsRequestID
+	}
+	if xray, err := XRayTraceHeaderFromContext(ctx); err == nil {
+		fields[xrayTraceIDTag] = xray.Root
+	}
+	if span := SpanFromContext(ctx); span != nil && span.span != nil {
+		fields[traceIDField] = span.span.TraceID
+		fields[spanIDField] = span.span.ID
+	}
+	return fields
+}
+
+// LoggerFromContext returns a log entry of the standard logger with the request correlation fields of the invocation
+// in ctx, see LogFields.
+func LoggerFromContext(ctx context.Context) *log.Entry {
+	return log.WithFields(LogFields(ctx))
+}
+
+// LogHook is a logrus.Hook adding the request correlation fields of the current invocation to every log entry made
+// during the invocation. Fields already set on the entry are kept. Log entries do not tell which invocation they belong
+// to, so with concurrent invocations no fields are added, use LoggerFromContext instead.
+type LogHook struct{}
+
+// NewLogHook is a LogHook creating factory function.
+func NewLogHook() *LogHook {
+	return &LogHook{}
+}
+
+// Levels is LogHook's logrus.Hook implementation. LogHook fires for all levels.
+func (h *LogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire is LogHook's logrus.Hook implementation.
+func (h *LogHook) Fire(entry *log.Entry) error {
+	invocationLogFieldsMu.Lock()
+	defer invocationLogFieldsMu.Unlock()
+	if len(invocationLogFields) != 1 {
+		return nil
+	}
+	// entry.Data may be shared with an entry reused by the caller, so the fields are added to a copy.
+	data := make(log.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	for _, fields := range invocationLogFields {
+		for k, v := range fields {
+			if _, ok := data[k]; !ok {
+				data[k] = v
+			}
+		}
+	}
+	entry.Data = data
+	return nil
+}
+
+// NewLogger creates a logrus.Logger writing to stdout with a LogHook. The logger uses the JSON formatter if json is
+// true, otherwise the text formatter.
+func NewLogger(json bool) *log.Logger {
+	logger := log.New()
+	logger.Out = os.Stdout
+	if json {
+		logger.Formatter = &log.JSONFormatter{}
+	}
+	logger.AddHook(NewLogHook())
+	return logger
+}
+
+// setInvocationLogFields sets the request correlation fields of the invocation in ctx added by LogHook, keyed by ctx.
+// The returned function unsets them.
+func setInvocationLogFields(ctx context.Context) func() {
+	invocationLogFieldsMu.Lock()
+	defer invocationLogFieldsMu.Unlock()
+	invocationLogFields[ctx] = LogFields(ctx)
+	return func() {
+		invocationLogFieldsMu.Lock()
+		defer invocationLogFieldsMu.Unlock()
+		delete(invocationLogFields, ctx)
+	}
+}
@@ -0,0 +1,157 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/trace"
+)
+
+func TestLogHook(t *testing.T) {
+	savedSendDatapoints, savedSendSpans, savedTracingEnabled := sendDatapoints, sendSpans, tracingEnabled
+	defer func() {
+		sendDatapoints, sendSpans, tracingEnabled = savedSendDatapoints, savedSendSpans, savedTracingEnabled
+	}()
+	sendDatapoints = func(context.Context, []*datapoint.Datapoint) error {
+		return nil
+	}
+	var spans []*trace.Span
+	sendSpans = func(_ context.Context, got []*trace.Span) error {
+		spans = got
+		return nil
+	}
+	tracingEnabled = true
+	var out bytes.Buffer
+	logger := NewLogger(true)
+	logger.Out = &out
+	handlerFunc := func() error {
+		logger.WithField(requestIDField, "kept").Info("first")
+		logger.Info("second")
+		return nil
+	}
+	c := lambdacontext.NewContext(context.TODO(), &lambdacontext.LambdaContext{
+		AwsRequestID:       "request-id",
+		InvokedFunctionArn: "arn:aws:lambda:us-east-1:accountId:function:functionName",
+	})
+	if _, err := NewHandlerWrapper(lambda.NewHandler(handlerFunc)).Invoke(c, []byte(`""`)); err != nil {
+		t.Fatalf("valid lambda handler function invocation error. got %+v", err)
+	}
+	logger.Info("after")
+	decoder := json.NewDecoder(&out)
+	var entries []map[string]interface{}
+	for decoder.More() {
+		var entry map[string]interface{}
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("want 3 log entries got %d", len(entries))
+	}
+	if entries[0][requestIDField] != "kept" {
+		t.Errorf("want entry field kept got %v", entries[0][requestIDField])
+	}
+	if entries[1][requestIDField] != "request-id" || entries[1][traceIDField] != spans[0].TraceID || entries[1][spanIDField] != spans[0].ID {
+		t.Errorf("want request correlation fields got %+v", entries[1])
+	}
+	if _, ok := entries[2][requestIDField]; ok {
+		t.Errorf("want no request correlation fields after invocation got %+v", entries[2])
+	}
+}
+
+func TestLogHookConcurrentInvocations(t *testing.T) {
+	savedSendDatapoints := sendDatapoints
+	defer func() {
+		sendDatapoints = savedSendDatapoints
+	}()
+	sendDatapoints = func(context.Context, []*datapoint.Datapoint) error {
+		return nil
+	}
+	var out bytes.Buffer
+	logger := NewLogger(true)
+	logger.Out = &out
+	started, panicked := make(chan struct{}), make(chan struct{})
+	first := NewHandlerWrapper(lambda.NewHandler(func() error {
+		logger.Info("alone")
+		close(started)
+		<-panicked
+		logger.Info("alone again")
+		return nil
+	}))
+	second := NewHandlerWrapper(lambda.NewHandler(func() error {
+		logger.Info("concurrent")
+		panic("boom")
+	}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		first.Invoke(lambdacontext.NewContext(context.TODO(), &lambdacontext.LambdaContext{AwsRequestID: "first"}), []byte(`""`))
+	}()
+	<-started
+	func() {
+		defer func() {
+			recover()
+			close(panicked)
+		}()
+		second.Invoke(lambdacontext.NewContext(context.TODO(), &lambdacontext.LambdaContext{AwsRequestID: "second"}), []byte(`""`))
+	}()
+	<-done
+	logger.Info("after")
+	decoder := json.NewDecoder(&out)
+	var got []interface{}
+	for decoder.More() {
+		var entry map[string]interface{}
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, entry[requestIDField])
+	}
+	if want := []interface{}{"first", nil, "first", nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want request ids %v got %v", want, got)
+	}
+}
+
+func TestLogHookReusedEntry(t *testing.T) {
+	savedSendDatapoints := sendDatapoints
+	defer func() {
+		sendDatapoints = savedSendDatapoints
+	}()
+	sendDatapoints = func(context.Context, []*datapoint.Datapoint) error {
+		return nil
+	}
+	var out bytes.Buffer
+	logger := NewLogger(true)
+	logger.Out = &out
+	entry := logger.WithField("component", "handler")
+	hw := NewHandlerWrapper(lambda.NewHandler(func() error {
+		entry.Info("invoked")
+		return nil
+	}))
+	for _, requestID := range []string{"first", "second"} {
+		if _, err := hw.Invoke(lambdacontext.NewContext(context.TODO(), &lambdacontext.LambdaContext{AwsRequestID: requestID}), []byte(`""`)); err != nil {
+			t.Fatalf("valid lambda handler function invocation error. got %+v", err)
+		}
+	}
+	decoder := json.NewDecoder(&out)
+	var got []interface{}
+	for decoder.More() {
+		var e map[string]interface{}
+		if err := decoder.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e[requestIDField])
+	}
+	if want := []interface{}{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want request ids %v got %v", want, got)
+	}
+	if _, ok := entry.Data[requestIDField]; ok {
+		t.Errorf("want reused entry unchanged got %+v", entry.Data)
+	}
+}
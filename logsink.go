@@ -0,0 +1,44 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/lambda-go/logformat"
+)
+
+// logSink is a sfxclient.Sink writing datapoints as metric log lines to out, see package logformat. A CloudWatch Logs
+// subscription forwarder converts the lines back into datapoints.
+type logSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// AddDatapoints is logSink's sfxclient.Sink implementation. Datapoints that cannot be encoded are skipped and reported
+// in the returned error.
+func (s *logSink) AddDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
+	var buf bytes.Buffer
+	var errs []error
+	for _, dp := range dps {
+		line, err := logformat.Encode(dp)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.out.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("error writing metric log lines. %+v", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error encoding metric log lines. %+v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/lambda-go/logformat"
+)
+
+func TestLogSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := &logSink{out: &out}
+	dps := []*datapoint.Datapoint{
+		{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter, Timestamp: time.Now()},
+		{Metric: "bad", Value: datapoint.NewStringValue("x"), MetricType: datapoint.Gauge, Timestamp: time.Now()},
+		{Metric: "function.duration", Value: datapoint.NewIntValue(3), MetricType: datapoint.Gauge, Timestamp: time.Now()},
+	}
+	if err := sink.AddDatapoints(context.TODO(), dps); err == nil {
+		t.Errorf("want error for string value")
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("want 2 lines got %d", len(lines))
+	}
+	for i, metric := range []string{"function.invocations", "function.duration"} {
+		dp, err := logformat.Parse(lines[i])
+		if err != nil {
+			t.Fatalf("want no error got %+v", err)
+		}
+		if dp.Metric != metric {
+			t.Errorf("want metric %s got %s", metric, dp.Metric)
+		}
+	}
+}
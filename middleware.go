@@ -0,0 +1,116 @@
+package sfxlambda
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	log "github.com/sirupsen/logrus"
+)
+
+// Middleware wraps a lambda.Handler to add behavior around its invocation.
+type Middleware func(lambda.Handler) lambda.Handler
+
+// HandlerFunc is a lambda.Handler implementation calling the function itself. It is meant for writing middlewares.
+type HandlerFunc func(ctx context.Context, payload []byte) ([]byte, error)
+
+// Invoke is HandlerFunc's lambda.Handler implementation.
+func (f HandlerFunc) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	return f(ctx, payload)
+}
+
+// Chain wraps handler with middlewares. The first middleware is the outermost one, i.e. it is invoked first and
+// returns last.
+func Chain(handler lambda.Handler, middlewares ...Middleware) lambda.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Metrics returns the Middleware sending the wrapper metrics and spans, see NewHandlerWrapper.
+func Metrics() Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return NewHandlerWrapper(next)
+	}
+}
+
+// Recover returns a Middleware turning panics of the next handler into errors. The stack trace of the panic is logged.
+func Recover() Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return HandlerFunc(func(ctx context.Context, payload []byte) (response []byte, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Errorf("recovered panic in lambda handler: %v\n%s", r, debug.Stack())
+					response, err = nil, fmt.Errorf("panic in lambda handler: %v", r)
+				}
+			}()
+			return next.Invoke(ctx, payload)
+		})
+	}
+}
+
+// TimeoutWatchdog returns a Middleware that returns an error when the next handler is still running margin before the
+// invocation deadline, so that outer middlewares such as Metrics can record the timeout before the Lambda runtime stops
+// the function. The next handler keeps running in the background until it returns or the function is stopped. Panics
+// of the next handler are passed on to the caller, or logged when they happen after the watchdog returned. The watchdog
+// is skipped when the invocation has no deadline or less than margin left.
+func TimeoutWatchdog(margin time.Duration) Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			deadline, ok := ctx.Deadline()
+			if !ok || time.Until(deadline) <= margin {
+				return next.Invoke(ctx, payload)
+			}
+			type result struct {
+				response []byte
+				err      error
+				panicked interface{}
+			}
+			done := make(chan result, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- result{panicked: r}
+					}
+				}()
+				response, err := next.Invoke(ctx, payload)
+				done <- result{response: response, err: err}
+			}()
+			timer := time.NewTimer(time.Until(deadline.Add(-margin)))
+			defer timer.Stop()
+			select {
+			case r := <-done:
+				if r.panicked != nil {
+					panic(r.panicked)
+				}
+				return r.response, r.err
+			case <-timer.C:
+				go func() {
+					if r := <-done; r.panicked != nil {
+						log.Errorf("panic in lambda handler after the timeout watchdog: %v", r.panicked)
+					}
+				}()
+				return nil, fmt.Errorf("lambda handler still running %s before the invocation deadline", margin)
+			}
+		})
+	}
+}
+
+// PayloadLogging returns a Middleware logging the payload, response and error of the next handler at debug level.
+func PayloadLogging() Middleware {
+	return func(next lambda.Handler) lambda.Handler {
+		return HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+			log.Debugf("lambda handler payload: %s", payload)
+			response, err := next.Invoke(ctx, payload)
+			if err != nil {
+				log.Debugf("lambda handler error: %+v", err)
+			} else {
+				log.Debugf("lambda handler response: %s", response)
+			}
+			return response, err
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package sfxlambda
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next lambda.Handler) lambda.Handler {
+			return HandlerFunc(func(ctx context.Context, payload []byte) ([]byte, error) {
+				calls = append(calls, name+" in")
+				response, err := next.Invoke(ctx, payload)
+				calls = append(calls, name+" out")
+				return response, err
+			})
+		}
+	}
+	handler := Chain(lambda.NewHandler(func() error {
+		calls = append(calls, "handler")
+		return nil
+	}), trace("first"), trace("second"))
+	if _, err := handler.Invoke(ctx, []byte(`""`)); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	want := "first in,second in,handler,second out,first out"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("want %s got %s", want, got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	handler := Chain(HandlerFunc(func(context.Context, []byte) ([]byte, error) {
+		panic("boom")
+	}), Recover())
+	if _, err := handler.Invoke(ctx, nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("want panic error got %+v", err)
+	}
+}
+
+func TestTimeoutWatchdog(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	handler := Chain(HandlerFunc(func(context.Context, []byte) ([]byte, error) {
+		<-release
+		return nil, nil
+	}), TimeoutWatchdog(time.Second))
+	c, cancel := context.WithTimeout(ctx, time.Second+50*time.Millisecond)
+	defer cancel()
+	if _, err := handler.Invoke(c, nil); err == nil {
+		t.Errorf("want timeout watchdog error")
+	}
+	handler = Chain(HandlerFunc(func(context.Context, []byte) ([]byte, error) {
+		return []byte(`"ok"`), nil
+	}), TimeoutWatchdog(time.Second))
+	if response, err := handler.Invoke(ctx, nil); err != nil || string(response) != `"ok"` {
+		t.Errorf("want response \"ok\" got %s %+v", response, err)
+	}
+	handler = Chain(HandlerFunc(func(context.Context, []byte) ([]byte, error) {
+		time.Sleep(50 * time.Millisecond)
+		return []byte(`"ok"`), nil
+	}), TimeoutWatchdog(time.Second))
+	c, cancel = context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	if response, err := handler.Invoke(c, nil); err != nil || string(response) != `"ok"` {
+		t.Errorf("want watchdog skipped with less than margin left got %s %+v", response, err)
+	}
+}
+
+func TestTimeoutWatchdogPanic(t *testing.T) {
+	handler := Chain(HandlerFunc(func(context.Context, []byte) ([]byte, error) {
+		panic("boom")
+	}), Recover(), TimeoutWatchdog(time.Second))
+	c, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if _, err := handler.Invoke(c, nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("want recovered panic error got %+v", err)
+	}
+}
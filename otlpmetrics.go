@@ -0,0 +1,260 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+const (
+	defaultOTLPEndpoint = "http://localhost:4318"
+	otlpMetricsPath     = "/v1/metrics"
+
+	otlpDeltaTemporality = 1
+)
+
+// otlpResourceAttributes maps default dimensions to OpenTelemetry FaaS and cloud resource semantic conventions.
+var otlpResourceAttributes = map[string]string{
+	"aws_function_name":    "faas.name",
+	"aws_function_version": "faas.version",
+	"aws_region":           "cloud.region",
+	"aws_account_id":       "cloud.account.id",
+	"lambda_arn":           "cloud.resource_id",
+}
+
+// durationMetrics are the gauges of durations in milliseconds. They are exported as OTLP histograms of a single
+// observation per datapoint and as StatsD timings.
+var durationMetrics = map[string]bool{
+	"function.duration":    true,
+	"http.duration":        true,
+	"http.client.duration": true,
+	"db.query.duration":    true,
+}
+
+// otlpHistogramBounds are the explicit bucket bounds of OTLP histograms in milliseconds, the OpenTelemetry SDK default.
+var otlpHistogramBounds = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// otlpMetricsSink is a sfxclient.Sink exporting datapoints as OTLP/HTTP protobuf metrics. Counters are exported as
+// delta monotonic sums, duration gauges as histograms and other gauges as gauges. Default dimensions become resource
+// attributes following the FaaS semantic conventions, other dimensions become datapoint attributes.
+type otlpMetricsSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// AddDatapoints is otlpMetricsSink's sfxclient.Sink implementation.
+func (s *otlpMetricsSink) AddDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
+	if len(dps) == 0 {
+		return nil
+	}
+	return postOTLP(ctx, s.client, s.endpoint, encodeOTLPMetrics(dps))
+}
+
+// postOTLP sends the OTLP/HTTP protobuf request body to endpoint.
+func postOTLP(ctx context.Context, client *http.Client, endpoint string, body []byte) error {
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create otlp request to %s. %+v", endpoint, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send otlp request. %+v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("invalid otlp response status code %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// otlpEndpoint returns the OTLP/HTTP endpoint of signal path, e.g. /v1/metrics. The endpoint is the value of the
+// signal specific environment variable or else the value of OTEL_EXPORTER_OTLP_ENDPOINT, or its default, with path.
+func otlpEndpoint(signalEnv, path string) string {
+	if endpoint := strings.TrimSpace(os.Getenv(signalEnv)); endpoint != "" {
+		return endpoint
+	}
+	base := strings.TrimSpace(os.Getenv(otelExporterOTLPEndpoint))
+	if base == "" {
+		base = defaultOTLPEndpoint
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// splitResourceAttributes splits dims into resource attributes and the remaining attributes.
+func splitResourceAttributes(dims map[string]string) (resource, attributes map[string]string) {
+	resource = map[string]string{"cloud.provider": "aws", "cloud.platform": "aws_lambda"}
+	attributes = map[string]string{}
+	for k, v := range dims {
+		if attr, ok := otlpResourceAttributes[k]; ok {
+			resource[attr] = v
+		} else {
+			attributes[k] = v
+		}
+	}
+	return resource, attributes
+}
+
+// sortedKeys returns the keys of attrs in increasing order.
+func sortedKeys(attrs map[string]string) []string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// attributesKey returns a key identifying the set of attributes attrs, independent of the map iteration order.
+func attributesKey(attrs map[string]string) string {
+	var key []string
+	for _, k := range sortedKeys(attrs) {
+		key = append(key, strconv.Quote(k)+"="+strconv.Quote(attrs[k]))
+	}
+	return strings.Join(key, ",")
+}
+
+// encodeKeyValues encodes attrs sorted by key as repeated KeyValue field.
+func encodeKeyValues(p *protoBuffer, field int, attrs map[string]string) {
+	for _, k := range sortedKeys(attrs) {
+		v := attrs[k]
+		p.message(field, func(kv *protoBuffer) {
+			kv.string(1, k)
+			kv.message(2, func(value *protoBuffer) {
+				value.string(1, v)
+			})
+		})
+	}
+}
+
+// encodeResource encodes the Resource message with attrs as field.
+func encodeResource(p *protoBuffer, field int, attrs map[string]string) {
+	p.message(field, func(r *protoBuffer) {
+		encodeKeyValues(r, 1, attrs)
+	})
+}
+
+// encodeScope encodes the InstrumentationScope message of the wrapper as field.
+func encodeScope(p *protoBuffer, field int) {
+	p.message(field, func(s *protoBuffer) {
+		s.string(1, name)
+		s.string(2, version)
+	})
+}
+
+// encodeOTLPMetrics encodes dps as an ExportMetricsServiceRequest, with one ResourceMetrics per set of resource
+// attributes.
+func encodeOTLPMetrics(dps []*datapoint.Datapoint) []byte {
+	type resourceMetrics struct {
+		resource map[string]string
+		dps      []*datapoint.Datapoint
+		attrs    []map[string]string
+	}
+	var resources []*resourceMetrics
+	byKey := map[string]*resourceMetrics{}
+	for _, dp := range dps {
+		resource, attrs := splitResourceAttributes(dp.Dimensions)
+		key := attributesKey(resource)
+		rm, ok := byKey[key]
+		if !ok {
+			rm = &resourceMetrics{resource: resource}
+			byKey[key] = rm
+			resources = append(resources, rm)
+		}
+		rm.dps = append(rm.dps, dp)
+		rm.attrs = append(rm.attrs, attrs)
+	}
+	var req protoBuffer
+	for _, rm := range resources {
+		req.message(1, func(r *protoBuffer) {
+			encodeResource(r, 1, rm.resource)
+			r.message(2, func(sm *protoBuffer) {
+				encodeScope(sm, 1)
+				for i, dp := range rm.dps {
+					encodeOTLPMetric(sm, 2, dp, rm.attrs[i])
+				}
+			})
+		})
+	}
+	return req.Bytes()
+}
+
+// encodeOTLPMetric encodes dp with attributes attrs as a Metric message field.
+func encodeOTLPMetric(p *protoBuffer, field int, dp *datapoint.Datapoint, attrs map[string]string) {
+	timestamp := uint64(dp.Timestamp.UnixNano())
+	if dp.Timestamp.IsZero() {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	p.message(field, func(m *protoBuffer) {
+		m.string(1, dp.Metric)
+		switch {
+		case durationMetrics[dp.Metric]:
+			m.string(3, "ms")
+			value := datapointFloat(dp.Value)
+			m.message(9, func(h *protoBuffer) {
+				h.message(1, func(hdp *protoBuffer) {
+					hdp.fixed64(2, timestamp)
+					hdp.fixed64(3, timestamp)
+					hdp.fixed64(4, 1)
+					hdp.double(5, value)
+					counts := make([]uint64, len(otlpHistogramBounds)+1)
+					counts[sort.SearchFloat64s(otlpHistogramBounds, value)]++
+					hdp.packedFixed64(6, counts)
+					hdp.packedDouble(7, otlpHistogramBounds)
+					encodeKeyValues(hdp, 9, attrs)
+					hdp.double(11, value)
+					hdp.double(12, value)
+				})
+				h.varint(2, otlpDeltaTemporality)
+			})
+		case dp.MetricType == datapoint.Counter || dp.MetricType == datapoint.Count:
+			m.message(7, func(sum *protoBuffer) {
+				sum.message(1, func(ndp *protoBuffer) {
+					encodeNumberDataPoint(ndp, dp.Value, timestamp, attrs)
+				})
+				sum.varint(2, otlpDeltaTemporality)
+				sum.varint(3, 1)
+			})
+		default:
+			m.message(5, func(gauge *protoBuffer) {
+				gauge.message(1, func(ndp *protoBuffer) {
+					encodeNumberDataPoint(ndp, dp.Value, timestamp, attrs)
+				})
+			})
+		}
+	})
+}
+
+// encodeNumberDataPoint encodes the fields of a NumberDataPoint message.
+func encodeNumberDataPoint(p *protoBuffer, value datapoint.Value, timestamp uint64, attrs map[string]string) {
+	p.fixed64(2, timestamp)
+	p.fixed64(3, timestamp)
+	if v, ok := value.(datapoint.IntValue); ok {
+		p.fixed64(6, uint64(v.Int()))
+	} else {
+		p.double(4, datapointFloat(value))
+	}
+	encodeKeyValues(p, 7, attrs)
+}
+
+// datapointFloat returns the numeric value of v as float64, 0 for non numeric values.
+func datapointFloat(v datapoint.Value) float64 {
+	switch t := v.(type) {
+	case datapoint.IntValue:
+		return float64(t.Int())
+	case datapoint.FloatValue:
+		return t.Float()
+	}
+	return 0
+}
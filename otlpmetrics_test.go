@@ -0,0 +1,150 @@
+package sfxlambda
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestOTLPMetricsSink(t *testing.T) {
+	var body []byte
+	var contentType string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+
+	dims := map[string]string{"aws_function_name": "my-function", "aws_region": "us-east-1", "event_source": "sqs"}
+	now := time.Now()
+	sink := &otlpMetricsSink{endpoint: receiver.URL + otlpMetricsPath, client: receiver.Client()}
+	dps := []*datapoint.Datapoint{
+		{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter, Dimensions: dims, Timestamp: now},
+		{Metric: "function.duration", Value: datapoint.NewIntValue(30), MetricType: datapoint.Gauge, Dimensions: dims, Timestamp: now},
+		{Metric: "sqs.message_age", Value: datapoint.NewFloatValue(2.5), MetricType: datapoint.Gauge, Dimensions: dims, Timestamp: now},
+	}
+	if err := sink.AddDatapoints(context.TODO(), dps); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if contentType != "application/x-protobuf" {
+		t.Errorf("want content type application/x-protobuf got %s", contentType)
+	}
+
+	resourceMetrics := protoFieldsNamed(decodeProto(t, body), 1)
+	if len(resourceMetrics) != 1 {
+		t.Fatalf("want 1 resource metrics got %d", len(resourceMetrics))
+	}
+	rm := decodeProto(t, resourceMetrics[0].b)
+	resource := protoAttributes(t, decodeProto(t, protoFieldsNamed(rm, 1)[0].b), 1)
+	for k, v := range map[string]string{"faas.name": "my-function", "cloud.region": "us-east-1", "cloud.provider": "aws", "cloud.platform": "aws_lambda"} {
+		if resource[k] != v {
+			t.Errorf("want resource attribute %s %s got %s", k, v, resource[k])
+		}
+	}
+	metrics := protoFieldsNamed(decodeProto(t, protoFieldsNamed(rm, 2)[0].b), 2)
+	if len(metrics) != len(dps) {
+		t.Fatalf("want %d metrics got %d", len(dps), len(metrics))
+	}
+	var tests = []struct {
+		name      string
+		dataField int
+	}{
+		{"function.invocations", 7},
+		{"function.duration", 9},
+		{"sqs.message_age", 5},
+	}
+	for i, test := range tests {
+		metric := decodeProto(t, metrics[i].b)
+		if name := string(protoFieldsNamed(metric, 1)[0].b); name != test.name {
+			t.Errorf("want metric %s got %s", test.name, name)
+		}
+		data := protoFieldsNamed(metric, test.dataField)
+		if len(data) != 1 {
+			t.Fatalf("want metric %s data field %d", test.name, test.dataField)
+		}
+		dataFields := decodeProto(t, data[0].b)
+		dataPoint := decodeProto(t, protoFieldsNamed(dataFields, 1)[0].b)
+		attrsField := 7
+		switch test.dataField {
+		case 7:
+			if temporality := protoFieldsNamed(dataFields, 2)[0].v; temporality != otlpDeltaTemporality {
+				t.Errorf("want delta temporality got %d", temporality)
+			}
+			if value := protoFieldsNamed(dataPoint, 6)[0].v; value != 1 {
+				t.Errorf("want sum value 1 got %d", value)
+			}
+		case 9:
+			attrsField = 9
+			if count := protoFieldsNamed(dataPoint, 4)[0].v; count != 1 {
+				t.Errorf("want histogram count 1 got %d", count)
+			}
+			if sum := math.Float64frombits(protoFieldsNamed(dataPoint, 5)[0].v); sum != 30 {
+				t.Errorf("want histogram sum 30 got %f", sum)
+			}
+		case 5:
+			if value := math.Float64frombits(protoFieldsNamed(dataPoint, 4)[0].v); value != 2.5 {
+				t.Errorf("want gauge value 2.5 got %f", value)
+			}
+		}
+		if attrs := protoAttributes(t, dataPoint, attrsField); len(attrs) != 1 || attrs["event_source"] != "sqs" {
+			t.Errorf("want attributes event_source sqs got %v", attrs)
+		}
+		if timestamp := protoFieldsNamed(dataPoint, 3)[0].v; timestamp != uint64(now.UnixNano()) {
+			t.Errorf("want timestamp %d got %d", now.UnixNano(), timestamp)
+		}
+	}
+}
+
+func TestOTLPMetricsSinkError(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+	sink := &otlpMetricsSink{endpoint: receiver.URL, client: receiver.Client()}
+	dps := []*datapoint.Datapoint{{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter}}
+	if err := sink.AddDatapoints(context.TODO(), dps); err == nil {
+		t.Errorf("want error for status code %d", http.StatusBadRequest)
+	}
+}
+
+func TestOTLPEndpoint(t *testing.T) {
+	var tests = []struct {
+		endpoint, metricsEndpoint, want string
+	}{
+		{"", "", "http://localhost:4318/v1/metrics"},
+		{"http://collector:4318/", "", "http://collector:4318/v1/metrics"},
+		{"http://collector:4318", "http://other:4318/custom", "http://other:4318/custom"},
+	}
+	savedEndpoint, savedMetricsEndpoint := os.Getenv(otelExporterOTLPEndpoint), os.Getenv(otelExporterOTLPMetricsEndpoint)
+	defer func() {
+		os.Setenv(otelExporterOTLPEndpoint, savedEndpoint)
+		os.Setenv(otelExporterOTLPMetricsEndpoint, savedMetricsEndpoint)
+	}()
+	for _, test := range tests {
+		os.Setenv(otelExporterOTLPEndpoint, test.endpoint)
+		os.Setenv(otelExporterOTLPMetricsEndpoint, test.metricsEndpoint)
+		if got := otlpEndpoint(otelExporterOTLPMetricsEndpoint, otlpMetricsPath); got != test.want {
+			t.Errorf("want %s got %s", test.want, got)
+		}
+	}
+}
+
+func TestAttributesKey(t *testing.T) {
+	a := map[string]string{"service.name": "f", "cloud.region": "us-east-1", "faas.version": "1"}
+	b := map[string]string{"faas.version": "1", "service.name": "f", "cloud.region": "us-east-1"}
+	if attributesKey(a) != attributesKey(b) {
+		t.Errorf("want equal keys for equal attributes got %s and %s", attributesKey(a), attributesKey(b))
+	}
+	c := map[string]string{"a": `b","c"="d`}
+	d := map[string]string{"a": "b", "c": "d"}
+	if attributesKey(c) == attributesKey(d) {
+		t.Errorf("want different keys for different attributes got %s", attributesKey(c))
+	}
+}
@@ -0,0 +1,202 @@
+package sfxlambda
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/signalfx/golib/trace"
+)
+
+const (
+	otlpTracesPath = "/v1/traces"
+
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+
+	otlpStatusCodeError = 2
+)
+
+// otlpTriggers maps the event_source span tag to the faas.trigger attribute values of the FaaS semantic conventions.
+var otlpTriggers = map[string]string{
+	sqsSource:            "pubsub",
+	snsSource:            "pubsub",
+	eventBridgeSource:    "pubsub",
+	s3Source:             "datasource",
+	kinesisSource:        "datasource",
+	dynamoDBSource:       "datasource",
+	cloudWatchLogsSource: "datasource",
+	scheduleSource:       "timer",
+	apiGatewaySource:     "http",
+	albSource:            "http",
+	directSource:         "other",
+}
+
+// otlpTracesSink is a trace.Sink exporting spans as OTLP/HTTP protobuf traces. The spans sent together, i.e. the spans
+// of one invocation, share one resource made of the default dimension tags following the FaaS semantic conventions and
+// the service.name of the function. The invocation span carries the faas.trigger, faas.coldstart and
+// faas.invocation_id attributes.
+type otlpTracesSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// AddSpans is otlpTracesSink's trace.Sink implementation.
+func (s *otlpTracesSink) AddSpans(ctx context.Context, spans []*trace.Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := encodeOTLPTraces(spans)
+	if err != nil {
+		return err
+	}
+	return postOTLP(ctx, s.client, s.endpoint, body)
+}
+
+// encodeOTLPTraces encodes spans as an ExportTraceServiceRequest of a single ResourceSpans.
+func encodeOTLPTraces(spans []*trace.Span) ([]byte, error) {
+	resource := map[string]string{}
+	attributes := make([]map[string]string, len(spans))
+	for i, span := range spans {
+		var spanResource map[string]string
+		spanResource, attributes[i] = splitResourceAttributes(span.Tags)
+		for k, v := range spanResource {
+			resource[k] = v
+		}
+		if span.LocalEndpoint != nil && span.LocalEndpoint.ServiceName != nil {
+			resource["service.name"] = *span.LocalEndpoint.ServiceName
+		}
+	}
+	var encodeErr error
+	var req protoBuffer
+	req.message(1, func(rs *protoBuffer) {
+		encodeResource(rs, 1, resource)
+		rs.message(2, func(ss *protoBuffer) {
+			encodeScope(ss, 1)
+			for i, span := range spans {
+				if err := encodeOTLPSpan(ss, 2, span, attributes[i]); err != nil && encodeErr == nil {
+					encodeErr = err
+				}
+			}
+		})
+	})
+	if encodeErr != nil {
+		return nil, encodeErr
+	}
+	return req.Bytes(), nil
+}
+
+// encodeOTLPSpan encodes span with attributes attrs as a Span message field.
+func encodeOTLPSpan(p *protoBuffer, field int, span *trace.Span, attrs map[string]string) error {
+	traceID, err := otlpID(span.TraceID, 16)
+	if err != nil {
+		return err
+	}
+	spanID, err := otlpID(span.ID, 8)
+	if err != nil {
+		return err
+	}
+	var parentID []byte
+	if span.ParentID != nil {
+		if parentID, err = otlpID(*span.ParentID, 8); err != nil {
+			return err
+		}
+	}
+	var start, end uint64
+	if span.Timestamp != nil {
+		start = uint64(*span.Timestamp) * 1e3
+		end = start
+		if span.Duration != nil {
+			end += uint64(*span.Duration) * 1e3
+		}
+	}
+	kind := otlpSpanKindInternal
+	if span.Kind != nil {
+		switch *span.Kind {
+		case serverKind:
+			kind = otlpSpanKindServer
+		case clientKind:
+			kind = otlpSpanKindClient
+		}
+	}
+	// The invocation span tags are mapped to FaaS attributes, the error tags to the span status.
+	_, coldStart := attrs["cold_start"]
+	delete(attrs, "cold_start")
+	if kind == otlpSpanKindServer && span.ParentID == nil {
+		if requestID, ok := attrs["aws_request_id"]; ok {
+			attrs["faas.invocation_id"] = requestID
+			delete(attrs, "aws_request_id")
+		}
+		if trigger, ok := otlpTriggers[attrs["event_source"]]; ok {
+			attrs["faas.trigger"] = trigger
+		}
+	}
+	errorMessage, hasError := attrs["error.message"]
+	hasError = hasError || attrs["error"] == "true"
+	delete(attrs, "error")
+	delete(attrs, "error.message")
+
+	p.message(field, func(s *protoBuffer) {
+		s.bytes(1, traceID)
+		s.bytes(2, spanID)
+		if parentID != nil {
+			s.bytes(4, parentID)
+		}
+		if span.Name != nil {
+			s.string(5, *span.Name)
+		}
+		s.varint(6, uint64(kind))
+		s.fixed64(7, start)
+		s.fixed64(8, end)
+		encodeKeyValues(s, 9, attrs)
+		if kind == otlpSpanKindServer && span.ParentID == nil {
+			s.message(9, func(kv *protoBuffer) {
+				kv.string(1, "faas.coldstart")
+				kv.message(2, func(value *protoBuffer) {
+					value.varint(2, boolVarint(coldStart))
+				})
+			})
+		}
+		for _, annotation := range span.Annotations {
+			s.message(11, func(event *protoBuffer) {
+				if annotation.Timestamp != nil {
+					event.fixed64(1, uint64(*annotation.Timestamp)*1e3)
+				}
+				if annotation.Value != nil {
+					event.string(2, *annotation.Value)
+				}
+			})
+		}
+		if hasError {
+			s.message(15, func(status *protoBuffer) {
+				status.string(2, errorMessage)
+				status.varint(3, otlpStatusCodeError)
+			})
+		}
+	})
+	return nil
+}
+
+// otlpID decodes the lowercase hexadecimal span or trace id into n bytes, left padding shorter ids with zeros, e.g.
+// 64-bit trace ids.
+func otlpID(id string, n int) ([]byte, error) {
+	if len(id) > 2*n {
+		return nil, fmt.Errorf("id %s longer than %d bytes", id, n)
+	}
+	b, err := hex.DecodeString(strings.Repeat("0", 2*n-len(id)) + id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %s. %+v", id, err)
+	}
+	return b, nil
+}
+
+// boolVarint returns the protocol buffers varint value of b.
+func boolVarint(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package sfxlambda
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/trace"
+)
+
+func TestOTLPTracesSink(t *testing.T) {
+	savedSendDatapoints, savedSendSpans, savedTracingEnabled := sendDatapoints, sendSpans, tracingEnabled
+	defer func() {
+		sendDatapoints, sendSpans, tracingEnabled = savedSendDatapoints, savedSendSpans, savedTracingEnabled
+	}()
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+	sink := &otlpTracesSink{endpoint: receiver.URL + otlpTracesPath, client: receiver.Client()}
+	sendDatapoints = func(context.Context, []*datapoint.Datapoint) error {
+		return nil
+	}
+	var spans []*trace.Span
+	sendSpans = func(ctx context.Context, got []*trace.Span) error {
+		spans = got
+		return sink.AddSpans(ctx, got)
+	}
+	tracingEnabled = true
+	handlerFunc := func(ctx context.Context) error {
+		span, _ := StartSpan(ctx, "db_query")
+		span.AddAnnotation("connected")
+		span.Finish()
+		return errors.New("failed")
+	}
+	invocationCtx := lambdacontext.NewContext(context.TODO(), &lambdacontext.LambdaContext{
+		AwsRequestID:       "c6af9ac6-7b61-11e6-9a41-93e812345678",
+		InvokedFunctionArn: "arn:aws:lambda:us-east-1:accountId:function:functionName:$LATEST",
+	})
+	if _, err := NewHandlerWrapper(lambda.NewHandler(handlerFunc)).Invoke(invocationCtx, []byte(sqsEvent)); err == nil {
+		t.Fatalf("want lambda handler function invocation error")
+	}
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans got %d", len(spans))
+	}
+
+	resourceSpans := protoFieldsNamed(decodeProto(t, body), 1)
+	if len(resourceSpans) != 1 {
+		t.Fatalf("want 1 resource spans got %d", len(resourceSpans))
+	}
+	rs := decodeProto(t, resourceSpans[0].b)
+	resource := protoAttributes(t, decodeProto(t, protoFieldsNamed(rs, 1)[0].b), 1)
+	if resource["cloud.region"] != "us-east-1" || resource["cloud.platform"] != "aws_lambda" {
+		t.Errorf("want resource attributes cloud.region and cloud.platform got %v", resource)
+	}
+	otlpSpans := protoFieldsNamed(decodeProto(t, protoFieldsNamed(rs, 2)[0].b), 2)
+	if len(otlpSpans) != 2 {
+		t.Fatalf("want 2 otlp spans got %d", len(otlpSpans))
+	}
+	child, invocation := decodeProto(t, otlpSpans[0].b), decodeProto(t, otlpSpans[1].b)
+
+	traceID, _ := otlpID(spans[1].TraceID, 16)
+	if got := protoFieldsNamed(invocation, 1)[0].b; string(got) != string(traceID) {
+		t.Errorf("want trace id %x got %x", traceID, got)
+	}
+	if kind := protoFieldsNamed(invocation, 6)[0].v; kind != otlpSpanKindServer {
+		t.Errorf("want server span kind got %d", kind)
+	}
+	if parent := protoFieldsNamed(child, 4); len(parent) != 1 || string(parent[0].b) != string(protoFieldsNamed(invocation, 2)[0].b) {
+		t.Errorf("want child span parent id the invocation span id")
+	}
+	attrs := protoAttributes(t, invocation, 9)
+	for k, v := range map[string]string{"faas.trigger": "pubsub", "faas.invocation_id": "c6af9ac6-7b61-11e6-9a41-93e812345678", "event_source": "sqs"} {
+		if attrs[k] != v {
+			t.Errorf("want attribute %s %s got %s", k, v, attrs[k])
+		}
+	}
+	if coldStart, ok := attrs["faas.coldstart"]; !ok || coldStart != "true" {
+		t.Errorf("want faas.coldstart attribute true got %s", coldStart)
+	}
+	status := protoFieldsNamed(invocation, 15)
+	if len(status) != 1 || protoFieldsNamed(decodeProto(t, status[0].b), 3)[0].v != otlpStatusCodeError {
+		t.Errorf("want error status on invocation span")
+	}
+	if events := protoFieldsNamed(child, 11); len(events) != 1 {
+		t.Errorf("want 1 span event got %d", len(events))
+	}
+}
+
+func TestOTLPID(t *testing.T) {
+	var tests = []struct {
+		id      string
+		n       int
+		want    string
+		wantErr bool
+	}{
+		{"80f198ee56343ba8", 16, "000000000000000080f198ee56343ba8", false},
+		{"5759e988bd862e3fe1be46a994272793", 16, "5759e988bd862e3fe1be46a994272793", false},
+		{"80f198ee56343ba8", 8, "80f198ee56343ba8", false},
+		{"80f198ee56343ba8aa", 8, "", true},
+		{"zz", 8, "", true},
+	}
+	for _, test := range tests {
+		got, err := otlpID(test.id, test.n)
+		if (err != nil) != test.wantErr {
+			t.Errorf("id %s. want error %t got %+v", test.id, test.wantErr, err)
+			continue
+		}
+		if err == nil && hex.EncodeToString(got) != test.want {
+			t.Errorf("id %s. want %s got %s", test.id, test.want, hex.EncodeToString(got))
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/signalfx/golib/datapoint"
+)
+
+const prometheusMetricNameLabel = "__name__"
+
+// prometheusRemoteWriteSink is a sfxclient.Sink sending datapoints as Prometheus remote write requests, i.e. snappy
+// compressed WriteRequest protobuf messages. Every datapoint is one time series of one sample. Metric names and
+// dimensions are sanitized into Prometheus metric and label names. Counters are per invocation deltas, not the
+// cumulative values Prometheus counters need, so they are sent as gauges without the _total suffix.
+type prometheusRemoteWriteSink struct {
+	url    string
+	client *http.Client
+}
+
+// AddDatapoints is prometheusRemoteWriteSink's sfxclient.Sink implementation.
+func (s *prometheusRemoteWriteSink) AddDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
+	if len(dps) == 0 {
+		return nil
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(snappy.Encode(nil, encodeWriteRequest(dps))))
+	if err != nil {
+		return fmt.Errorf("cannot create remote write request to %s. %+v", s.url, err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send remote write request. %+v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("invalid remote write response status code %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes dps as a Prometheus remote write WriteRequest message.
+func encodeWriteRequest(dps []*datapoint.Datapoint) []byte {
+	var req protoBuffer
+	for _, dp := range dps {
+		labels := prometheusLabels(dp)
+		names := make([]string, 0, len(labels))
+		for name := range labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		timestamp := dp.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		req.message(1, func(ts *protoBuffer) {
+			for _, name := range names {
+				ts.message(1, func(label *protoBuffer) {
+					label.string(1, name)
+					label.string(2, labels[name])
+				})
+			}
+			ts.message(2, func(sample *protoBuffer) {
+				sample.double(1, datapointFloat(dp.Value))
+				sample.varint(2, uint64(timestamp.UnixNano()/int64(time.Millisecond)))
+			})
+		})
+	}
+	return req.Bytes()
+}
+
+// prometheusLabels returns the labels of dp, the sanitized metric name as __name__ and the sanitized dimensions.
+func prometheusLabels(dp *datapoint.Datapoint) map[string]string {
+	labels := map[string]string{prometheusMetricNameLabel: prometheusName(dp.Metric, true)}
+	for k, v := range dp.Dimensions {
+		if label := prometheusName(k, false); label != prometheusMetricNameLabel && v != "" {
+			labels[label] = v
+		}
+	}
+	return labels
+}
+
+// prometheusName sanitizes s into a Prometheus metric name matching [a-zA-Z_:][a-zA-Z0-9_:]* or, if not metric, a label
+// name matching [a-zA-Z_][a-zA-Z0-9_]*. Invalid characters are replaced with underscores and a leading digit is
+// prefixed with one.
+func prometheusName(s string, metric bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && metric:
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
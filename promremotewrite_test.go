@@ -0,0 +1,104 @@
+package sfxlambda
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestPrometheusRemoteWriteSink(t *testing.T) {
+	var body []byte
+	var header http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+	sink := &prometheusRemoteWriteSink{url: receiver.URL, client: receiver.Client()}
+	now := time.Now()
+	dps := []*datapoint.Datapoint{
+		{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter,
+			Dimensions: map[string]string{"aws_function_name": "my-function", "http.method": "GET"}, Timestamp: now},
+		{Metric: "sqs.message_age", Value: datapoint.NewFloatValue(2.5), MetricType: datapoint.Gauge, Timestamp: now},
+	}
+	if err := sink.AddDatapoints(context.TODO(), dps); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if header.Get("Content-Encoding") != "snappy" || header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("want remote write headers got %v", header)
+	}
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("want snappy compressed body got %+v", err)
+	}
+	series := protoFieldsNamed(decodeProto(t, decoded), 1)
+	if len(series) != 2 {
+		t.Fatalf("want 2 time series got %d", len(series))
+	}
+	var tests = []struct {
+		labels []string
+		value  float64
+	}{
+		{[]string{"__name__", "function_invocations", "aws_function_name", "my-function", "http_method", "GET"}, 1},
+		{[]string{"__name__", "sqs_message_age"}, 2.5},
+	}
+	for i, test := range tests {
+		ts := decodeProto(t, series[i].b)
+		labels := protoFieldsNamed(ts, 1)
+		if len(labels) != len(test.labels)/2 {
+			t.Fatalf("want %d labels got %d", len(test.labels)/2, len(labels))
+		}
+		got := map[string]string{}
+		var names []string
+		for _, label := range labels {
+			fields := decodeProto(t, label.b)
+			name := string(protoFieldsNamed(fields, 1)[0].b)
+			got[name] = string(protoFieldsNamed(fields, 2)[0].b)
+			names = append(names, name)
+		}
+		for j := 0; j < len(test.labels); j += 2 {
+			if got[test.labels[j]] != test.labels[j+1] {
+				t.Errorf("want label %s %s got %s", test.labels[j], test.labels[j+1], got[test.labels[j]])
+			}
+		}
+		for j := 1; j < len(names); j++ {
+			if names[j-1] > names[j] {
+				t.Errorf("want labels sorted by name got %v", names)
+			}
+		}
+		sample := decodeProto(t, protoFieldsNamed(ts, 2)[0].b)
+		if value := math.Float64frombits(protoFieldsNamed(sample, 1)[0].v); value != test.value {
+			t.Errorf("want value %f got %f", test.value, value)
+		}
+		if timestamp := protoFieldsNamed(sample, 2)[0].v; timestamp != uint64(now.UnixNano()/int64(time.Millisecond)) {
+			t.Errorf("want timestamp in milliseconds got %d", timestamp)
+		}
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	var tests = []struct {
+		s      string
+		metric bool
+		want   string
+	}{
+		{"function.duration", true, "function_duration"},
+		{"ns:metric", true, "ns:metric"},
+		{"ns:label", false, "ns_label"},
+		{"2xx", false, "_2xx"},
+		{"status-code", false, "status_code"},
+		{"", false, "_"},
+	}
+	for _, test := range tests {
+		if got := prometheusName(test.s, test.metric); got != test.want {
+			t.Errorf("want %s got %s", test.want, got)
+		}
+	}
+}
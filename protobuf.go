@@ -0,0 +1,64 @@
+package sfxlambda
+
+import (
+	"math"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// protoBuffer encodes protocol buffers messages field by field with proto.Buffer, for the few messages the wrapper
+// sends without generated code.
+type protoBuffer struct {
+	proto.Buffer
+}
+
+func (p *protoBuffer) tag(field int, wireType int) {
+	p.EncodeVarint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (p *protoBuffer) varint(field int, v uint64) {
+	p.tag(field, proto.WireVarint)
+	p.EncodeVarint(v)
+}
+
+func (p *protoBuffer) fixed64(field int, v uint64) {
+	p.tag(field, proto.WireFixed64)
+	p.EncodeFixed64(v)
+}
+
+func (p *protoBuffer) double(field int, v float64) {
+	p.fixed64(field, math.Float64bits(v))
+}
+
+func (p *protoBuffer) bytes(field int, v []byte) {
+	p.tag(field, proto.WireBytes)
+	p.EncodeRawBytes(v)
+}
+
+func (p *protoBuffer) string(field int, v string) {
+	p.tag(field, proto.WireBytes)
+	p.EncodeStringBytes(v)
+}
+
+// message encodes the embedded message written by encode as field.
+func (p *protoBuffer) message(field int, encode func(*protoBuffer)) {
+	var m protoBuffer
+	encode(&m)
+	p.bytes(field, m.Bytes())
+}
+
+func (p *protoBuffer) packedFixed64(field int, vs []uint64) {
+	var m protoBuffer
+	for _, v := range vs {
+		m.EncodeFixed64(v)
+	}
+	p.bytes(field, m.Bytes())
+}
+
+func (p *protoBuffer) packedDouble(field int, vs []float64) {
+	var m protoBuffer
+	for _, v := range vs {
+		m.EncodeFixed64(math.Float64bits(v))
+	}
+	p.bytes(field, m.Bytes())
+}
@@ -0,0 +1,105 @@
+package sfxlambda
+
+import (
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// protoField is a decoded protocol buffers field. Varint and fixed64 values are in v, length delimited values in b.
+type protoField struct {
+	num int
+	v   uint64
+	b   []byte
+}
+
+// decodeProto decodes the fields of the protocol buffers message b.
+func decodeProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	var fields []protoField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("invalid field key in %x", b)
+		}
+		b = b[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case proto.WireVarint:
+			if f.v, n = binary.Uvarint(b); n <= 0 {
+				t.Fatalf("invalid varint in %x", b)
+			}
+			b = b[n:]
+		case proto.WireFixed64:
+			if len(b) < 8 {
+				t.Fatalf("invalid fixed64 in %x", b)
+			}
+			f.v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case proto.WireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				t.Fatalf("invalid length delimited field in %x", b)
+			}
+			f.b, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			t.Fatalf("unsupported wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// protoFieldsNamed returns the fields of fields numbered num.
+func protoFieldsNamed(fields []protoField, num int) []protoField {
+	var named []protoField
+	for _, f := range fields {
+		if f.num == num {
+			named = append(named, f)
+		}
+	}
+	return named
+}
+
+// protoAttributes decodes the repeated KeyValue field num of string and bool values of fields.
+func protoAttributes(t *testing.T, fields []protoField, num int) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range protoFieldsNamed(fields, num) {
+		kvFields := decodeProto(t, kv.b)
+		key := string(protoFieldsNamed(kvFields, 1)[0].b)
+		value := decodeProto(t, protoFieldsNamed(kvFields, 2)[0].b)
+		if s := protoFieldsNamed(value, 1); len(s) == 1 {
+			attrs[key] = string(s[0].b)
+		} else {
+			attrs[key] = strconv.FormatBool(protoFieldsNamed(value, 2)[0].v == 1)
+		}
+	}
+	return attrs
+}
+
+func TestProtoBuffer(t *testing.T) {
+	var p protoBuffer
+	p.varint(1, 300)
+	p.string(2, "testing")
+	p.message(3, func(m *protoBuffer) {
+		m.double(1, 1.5)
+	})
+	p.packedFixed64(4, []uint64{1, 2})
+	fields := decodeProto(t, p.Bytes())
+	if len(fields) != 4 {
+		t.Fatalf("want 4 fields got %d", len(fields))
+	}
+	if fields[0].num != 1 || fields[0].v != 300 {
+		t.Errorf("want field 1 value 300 got %+v", fields[0])
+	}
+	if fields[1].num != 2 || string(fields[1].b) != "testing" {
+		t.Errorf("want field 2 value testing got %+v", fields[1])
+	}
+	if embedded := decodeProto(t, fields[2].b); len(embedded) != 1 || embedded[0].v != 0x3ff8000000000000 {
+		t.Errorf("want embedded double 1.5 got %+v", embedded)
+	}
+	if len(fields[3].b) != 16 {
+		t.Errorf("want packed field of 16 bytes got %d", len(fields[3].b))
+	}
+}
@@ -0,0 +1,223 @@
+package sfxlambda
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+const (
+	routingBufferSize = 1000
+	routingBatchSize  = 500
+	routingMaxRetry   = 1
+
+	routingFlushInterval = 5 * time.Millisecond
+)
+
+// routingConfig is the JSON routing configuration of SIGNALFX_ROUTING_CONFIG, e.g.
+//
+//	{"rules": [{"metric_prefix": "business.", "token": "ORG_TOKEN", "realm": "us1"}]}
+type routingConfig struct {
+	Rules []*routingRule `json:"rules"`
+}
+
+// routingRule routes the datapoints whose metric name starts with MetricPrefix and which have all the Dimensions to the
+// org of Token. The datapoints are sent to the ingest endpoint of Realm, IngestEndpoint if set, or else the default
+// ingest endpoint.
+type routingRule struct {
+	MetricPrefix   string            `json:"metric_prefix"`
+	Dimensions     map[string]string `json:"dimensions"`
+	Token          string            `json:"token"`
+	Realm          string            `json:"realm"`
+	IngestEndpoint string            `json:"ingest_endpoint"`
+
+	sink *routeSink
+}
+
+func (r *routingRule) matches(dp *datapoint.Datapoint) bool {
+	if !strings.HasPrefix(dp.Metric, r.MetricPrefix) {
+		return false
+	}
+	for k, v := range r.Dimensions {
+		if dp.Dimensions[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// routeSink sends the datapoints of the rules of one ingest endpoint with the token set on the context, see
+// sfxclient.AsyncMultiTokenSink. Errors reported asynchronously by the sink are collected in errs.
+type routeSink struct {
+	sink *sfxclient.AsyncMultiTokenSink
+	mu   sync.Mutex
+	errs []error
+}
+
+func (s *routeSink) handleError(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+	return nil
+}
+
+func (s *routeSink) flushErrors() []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := s.errs
+	s.errs = nil
+	return errs
+}
+
+// buffered returns the number of datapoints added to the sink that are not sent yet.
+func (s *routeSink) buffered() int64 {
+	for _, dp := range s.sink.Datapoints() {
+		if dp.Metric == "total_datapoints_buffered" {
+			if v, ok := dp.Value.(datapoint.IntValue); ok {
+				return v.Int()
+			}
+		}
+	}
+	return 0
+}
+
+// routingSink is a sfxclient.Sink sending every datapoint to the token and ingest endpoint of the first routing rule
+// it matches. Datapoints matching no rule are sent to the default sink. As the lambda function may be frozen once the
+// handler returns, AddDatapoints waits until the routed datapoints are sent, up to timeout.
+type routingSink struct {
+	defaultSink sfxclient.Sink
+	rules       []*routingRule
+	timeout     time.Duration
+}
+
+// newRoutingSink returns a routingSink for config. It creates one sfxclient.AsyncMultiTokenSink per ingest endpoint of
+// the rules, using client to send datapoints. defaultEndpoint is the datapoint endpoint of rules without realm and
+// ingest endpoint.
+func newRoutingSink(config *routingConfig, defaultSink sfxclient.Sink, defaultEndpoint, userAgent string, client *http.Client) (*routingSink, error) {
+	sinks := map[string]*routeSink{}
+	for i, rule := range config.Rules {
+		if rule.Token == "" {
+			return nil, fmt.Errorf("no token in routing rule %d", i)
+		}
+		endpoint := defaultEndpoint
+		switch {
+		case rule.IngestEndpoint != "":
+			var err error
+			if endpoint, err = ingestEndpointURL(rule.IngestEndpoint, datapointPath); err != nil {
+				return nil, fmt.Errorf("error parsing ingest endpoint %s of routing rule %d. %+v", rule.IngestEndpoint, i, err)
+			}
+		case rule.Realm != "":
+			if err := validateRealm(rule.Realm); err != nil {
+				return nil, fmt.Errorf("invalid realm of routing rule %d. %+v", i, err)
+			}
+			endpoint = realmIngestEndpoint(rule.Realm) + "/" + datapointPath
+		}
+		sink, ok := sinks[endpoint]
+		if !ok {
+			sink = &routeSink{}
+			sink.sink = sfxclient.NewAsyncMultiTokenSink(1, 1, routingBufferSize, routingBatchSize, endpoint, "", "", userAgent,
+				func() *http.Client { return client }, sink.handleError, routingMaxRetry)
+			sinks[endpoint] = sink
+		}
+		rule.sink = sink
+	}
+	timeout := client.Timeout
+	if timeout <= 0 {
+		timeout = sfxclient.DefaultTimeout
+	}
+	return &routingSink{defaultSink: defaultSink, rules: config.Rules, timeout: timeout}, nil
+}
+
+// parseRoutingConfig parses the JSON routing configuration value, either the JSON document itself or the path of a
+// JSON file.
+func parseRoutingConfig(value string) (*routingConfig, error) {
+	b := []byte(value)
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		var err error
+		if b, err = ioutil.ReadFile(value); err != nil {
+			return nil, fmt.Errorf("error reading routing config file %s. %+v", value, err)
+		}
+	}
+	var config routingConfig
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("error parsing routing config. %+v", err)
+	}
+	return &config, nil
+}
+
+// AddDatapoints is routingSink's sfxclient.Sink implementation.
+func (s *routingSink) AddDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
+	var defaultDps []*datapoint.Datapoint
+	routed := map[*routingRule][]*datapoint.Datapoint{}
+	var rules []*routingRule
+	for _, dp := range dps {
+		rule := s.route(dp)
+		if rule == nil {
+			defaultDps = append(defaultDps, dp)
+			continue
+		}
+		if _, ok := routed[rule]; !ok {
+			rules = append(rules, rule)
+		}
+		routed[rule] = append(routed[rule], dp)
+	}
+	var errs []error
+	if len(defaultDps) > 0 {
+		if err := s.defaultSink.AddDatapoints(ctx, defaultDps); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sinks := map[*routeSink]bool{}
+	for _, rule := range rules {
+		if err := rule.sink.sink.AddDatapoints(context.WithValue(ctx, sfxclient.TokenCtxKey, rule.Token), routed[rule]); err != nil {
+			errs = append(errs, err)
+		}
+		sinks[rule.sink] = true
+	}
+	for sink := range sinks {
+		if err := s.wait(ctx, sink); err != nil {
+			errs = append(errs, err)
+		}
+		errs = append(errs, sink.flushErrors()...)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error sending routed datapoints. %+v", errs)
+	}
+	return nil
+}
+
+// route returns the first rule dp matches or nil if there is none.
+func (s *routingSink) route(dp *datapoint.Datapoint) *routingRule {
+	for _, rule := range s.rules {
+		if rule.matches(dp) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// wait waits until the datapoints added to sink are sent.
+func (s *routingSink) wait(ctx context.Context, sink *routeSink) error {
+	timeout := time.NewTimer(s.timeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(routingFlushInterval)
+	defer ticker.Stop()
+	for sink.buffered() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("routed datapoints not sent within %s", s.timeout)
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package sfxlambda
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/sfxclient"
+)
+
+func TestRoutingSink(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]string{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.Header.Get(sfxclient.TokenHeaderName)] = r.URL.Path
+		mu.Unlock()
+		w.Write([]byte(`"OK"`))
+	}))
+	defer receiver.Close()
+	config, err := parseRoutingConfig(`{"rules": [
+		{"metric_prefix": "business.", "token": "BUSINESS_TOKEN", "ingest_endpoint": "` + receiver.URL + `"},
+		{"dimensions": {"team": "payments"}, "token": "PAYMENTS_TOKEN"}
+	]}`)
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	defaultSink := &batchRecorder{}
+	sink, err := newRoutingSink(config, defaultSink, receiver.URL+"/custom/v2/datapoint", "test", receiver.Client())
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	dps := []*datapoint.Datapoint{
+		{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter},
+		{Metric: "business.orders", Value: datapoint.NewIntValue(3), MetricType: datapoint.Counter, Dimensions: map[string]string{"team": "payments"}},
+		{Metric: "payments.latency", Value: datapoint.NewIntValue(3), MetricType: datapoint.Gauge, Dimensions: map[string]string{"team": "payments"}},
+	}
+	if err := sink.AddDatapoints(context.TODO(), dps); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if len(defaultSink.batches) != 1 || len(defaultSink.batches[0]) != 1 || defaultSink.batches[0][0].Metric != "function.invocations" {
+		t.Errorf("want function.invocations sent to the default sink got %v", defaultSink.batches)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for token, path := range map[string]string{"BUSINESS_TOKEN": "/v2/datapoint", "PAYMENTS_TOKEN": "/custom/v2/datapoint"} {
+		if requests[token] != path {
+			t.Errorf("want datapoints sent with token %s to %s got %s", token, path, requests[token])
+		}
+	}
+}
+
+func TestRoutingSinkError(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer receiver.Close()
+	config := &routingConfig{Rules: []*routingRule{{MetricPrefix: "business.", Token: "INVALID"}}}
+	sink, err := newRoutingSink(config, &batchRecorder{}, receiver.URL, "test", receiver.Client())
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	dps := []*datapoint.Datapoint{{Metric: "business.orders", Value: datapoint.NewIntValue(3), MetricType: datapoint.Counter}}
+	if err := sink.AddDatapoints(context.TODO(), dps); err == nil {
+		t.Errorf("want error for status code %d", http.StatusUnauthorized)
+	}
+}
+
+func TestParseRoutingConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "routing.json")
+	if err := ioutil.WriteFile(path, []byte(`{"rules": [{"metric_prefix": "business.", "token": "TOKEN", "realm": "us1"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parseRoutingConfig(path)
+	if err != nil || len(config.Rules) != 1 || config.Rules[0].Realm != "us1" {
+		t.Errorf("want routing config file parsed got %+v %+v", config, err)
+	}
+	for _, value := range []string{`{"rules": [}`, filepath.Join(dir, "missing.json")} {
+		if _, err := parseRoutingConfig(value); err == nil {
+			t.Errorf("want error for routing config %s", value)
+		}
+	}
+	if _, err := newRoutingSink(&routingConfig{Rules: []*routingRule{{MetricPrefix: "business."}}}, nil, "", "", http.DefaultClient); err == nil {
+		t.Errorf("want error for routing rule without token")
+	}
+}
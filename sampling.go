@@ -0,0 +1,178 @@
+package sfxlambda
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	samplingReasonTag = "sampling.reason"
+	traceparentHeader = "traceparent"
+
+	sampledUpstream      = "upstream"
+	sampledProbabilistic = "probabilistic"
+	sampledError         = "error"
+	sampledSlow          = "slow"
+	notSampled           = "not_sampled"
+	rateLimited          = "rate_limited"
+)
+
+// sampler decides which invocation traces are sent. The head decision is made when the invocation starts and is
+// propagated to downstream services. The tail decision, made when the invocation ends, can only add erroneous and slow
+// invocations to the sampled ones.
+type sampler struct {
+	rate          float64
+	honorUpstream bool
+	// honorXRay uses the sampled flag of the X-Ray trace header as upstream decision. Lambda sets Sampled=0 on every
+	// invocation when active tracing is disabled, so it is only honored when the trace ids are taken from X-Ray.
+	honorXRay     bool
+	sampleErrors  bool
+	slowThreshold time.Duration
+	limiter       *rateLimiter
+
+	// mu guards rnd, created on first use and seeded with the current time since the global source is not seeded
+	// before Go 1.20.
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// sampling is the sampler of the wrapper, configured from environment variables.
+var sampling = &sampler{rate: 1}
+
+// newSampler returns the sampler of config. Invalid values, reported by Config.Validate, use the defaults.
+func newSampler(config *Config) *sampler {
+	s := &sampler{
+		rate:          1,
+		honorUpstream: config.enabled(sfxTraceHonorUpstream),
+		honorXRay:     config.enabled(sfxTraceIDFromXRay),
+		sampleErrors:  config.enabled(sfxTraceSampleErrors),
+	}
+	if config.validTraceSampleRate() {
+		s.rate = config.TraceSampleRate
+	}
+	if config.TraceSlowThreshold > 0 {
+		s.slowThreshold = config.TraceSlowThreshold
+	}
+	if config.TraceRateLimit > 0 {
+		s.limiter = newRateLimiter(config.TraceRateLimit)
+	}
+	return s
+}
+
+// headDecision decides whether the invocation trace is sampled based on the upstream sampled flags, if honored, or the
+// sample rate. Positive decisions are subject to the rate limiter.
+func (s *sampler) headDecision(ctx context.Context, ev *eventPayload) (bool, string) {
+	sampled, reason := false, notSampled
+	if upstream, ok := s.upstreamDecision(ctx, ev); ok {
+		if upstream {
+			sampled, reason = true, sampledUpstream
+		}
+	} else if s.rate >= 1 || s.float64() < s.rate {
+		sampled, reason = true, sampledProbabilistic
+	}
+	if sampled && s.limiter != nil && !s.limiter.allow(time.Now()) {
+		return false, rateLimited
+	}
+	return sampled, reason
+}
+
+// float64 returns a pseudo-random number in [0.0,1.0) from the source of s.
+func (s *sampler) float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rnd == nil {
+		s.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return s.rnd.Float64()
+}
+
+// tailDecision samples failed invocations and invocations slower than the threshold if enabled, regardless of the head
+// decision.
+func (s *sampler) tailDecision(sampled bool, reason string, elapsed time.Duration, err error) (bool, string) {
+	if sampled {
+		return sampled, reason
+	}
+	if err != nil && s.sampleErrors {
+		return true, sampledError
+	}
+	if s.slowThreshold > 0 && elapsed > s.slowThreshold {
+		return true, sampledSlow
+	}
+	return false, reason
+}
+
+// upstreamDecision returns the sampled flag of the B3 or W3C traceparent headers of an HTTP event ev, or else the X-Ray
+// trace header if honored. ok is false if upstream sampling is not honored or there is no flag.
+func (s *sampler) upstreamDecision(ctx context.Context, ev *eventPayload) (sampled bool, ok bool) {
+	if !s.honorUpstream {
+		return false, false
+	}
+	if ev != nil {
+		for k, v := range ev.Headers {
+			switch {
+			case strings.EqualFold(k, b3SampledHeader):
+				return v == "1" || v == "true" || v == "d", true
+			case strings.EqualFold(k, "b3"):
+				// Single header format {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId} or {SamplingState}.
+				fields := strings.Split(v, "-")
+				if len(fields) == 1 {
+					return fields[0] == "1" || fields[0] == "d", true
+				}
+				if len(fields) > 2 {
+					return fields[2] == "1" || fields[2] == "d", true
+				}
+			case strings.EqualFold(k, traceparentHeader):
+				// {Version}-{TraceId}-{ParentId}-{TraceFlags} with the sampled flag as lowest bit of the trace flags.
+				fields := strings.Split(v, "-")
+				if len(fields) >= 4 {
+					if flags, err := strconv.ParseUint(fields[3], 16, 8); err == nil {
+						return flags&1 == 1, true
+					}
+				}
+			}
+		}
+	}
+	if !s.honorXRay {
+		return false, false
+	}
+	if xray, err := XRayTraceHeaderFromContext(ctx); err == nil && xray.Sampled != "" && xray.Sampled != "?" {
+		return xray.Sampled == "1", true
+	}
+	return false, false
+}
+
+// rateLimiter is a token bucket limiting the number of sampled traces per second in the container.
+type rateLimiter struct {
+	mu       sync.Mutex
+	perSec   float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newRateLimiter(perSec float64) *rateLimiter {
+	burst := math.Max(perSec, 1)
+	return &rateLimiter{perSec: perSec, burst: burst, tokens: burst}
+}
+
+func (r *rateLimiter) allow(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.lastFill.IsZero() {
+		r.tokens += now.Sub(r.lastFill).Seconds() * r.perSec
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.lastFill = now
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
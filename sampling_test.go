@@ -0,0 +1,115 @@
+package sfxlambda
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSamplingDecisions(t *testing.T) {
+	xrayCtx := context.WithValue(context.TODO(), xrayTraceIDContextKey, "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=0")
+	var tests = []struct {
+		sampler     *sampler
+		ctx         context.Context
+		payload     string
+		elapsed     time.Duration
+		err         error
+		wantSampled bool
+		wantReason  string
+	}{
+		{&sampler{rate: 1}, context.TODO(), `{}`, 0, nil, true, sampledProbabilistic},
+		{&sampler{rate: 0}, context.TODO(), `{}`, 0, nil, false, notSampled},
+		{&sampler{rate: 0, honorUpstream: true}, context.TODO(), `{"headers":{"x-b3-sampled":"1"}}`, 0, nil, true, sampledUpstream},
+		{&sampler{rate: 1, honorUpstream: true}, context.TODO(), `{"headers":{"X-B3-Sampled":"0"}}`, 0, nil, false, notSampled},
+		{&sampler{rate: 0, honorUpstream: true}, context.TODO(), `{"headers":{"b3":"80f198ee56343ba8-e457b5a2e4d86bd1-1"}}`, 0, nil, true, sampledUpstream},
+		{&sampler{rate: 0, honorUpstream: true}, context.TODO(), `{"headers":{"traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}`, 0, nil, true, sampledUpstream},
+		{&sampler{rate: 1, honorUpstream: true}, context.TODO(), `{"headers":{"Traceparent":"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"}}`, 0, nil, false, notSampled},
+		{&sampler{rate: 1, honorUpstream: true}, xrayCtx, `{}`, 0, nil, true, sampledProbabilistic},
+		{&sampler{rate: 1, honorUpstream: true, honorXRay: true}, xrayCtx, `{}`, 0, nil, false, notSampled},
+		{&sampler{rate: 1}, xrayCtx, `{}`, 0, nil, true, sampledProbabilistic},
+		{&sampler{rate: 0, sampleErrors: true}, context.TODO(), `{}`, 0, errors.New("failed"), true, sampledError},
+		{&sampler{rate: 0, sampleErrors: true}, context.TODO(), `{}`, 0, nil, false, notSampled},
+		{&sampler{rate: 0, slowThreshold: time.Second}, context.TODO(), `{}`, 2 * time.Second, nil, true, sampledSlow},
+		{&sampler{rate: 0, slowThreshold: time.Second}, context.TODO(), `{}`, time.Millisecond, nil, false, notSampled},
+	}
+	for i, test := range tests {
+		sampled, reason := test.sampler.headDecision(test.ctx, parseEventPayload([]byte(test.payload)))
+		sampled, reason = test.sampler.tailDecision(sampled, reason, test.elapsed, test.err)
+		if sampled != test.wantSampled || reason != test.wantReason {
+			t.Errorf("test %d. want %t %s got %t %s", i, test.wantSampled, test.wantReason, sampled, reason)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2)
+	now := time.Now()
+	if !limiter.allow(now) || !limiter.allow(now) {
+		t.Errorf("want burst of 2 allowed")
+	}
+	if limiter.allow(now) {
+		t.Errorf("want third call within the same second rate limited")
+	}
+	if !limiter.allow(now.Add(500 * time.Millisecond)) {
+		t.Errorf("want call allowed after refill")
+	}
+	s := &sampler{rate: 1, limiter: newRateLimiter(1)}
+	s.headDecision(context.TODO(), nil)
+	if sampled, reason := s.headDecision(context.TODO(), nil); sampled || reason != rateLimited {
+		t.Errorf("want rate limited got %t %s", sampled, reason)
+	}
+}
+
+func TestNewSampler(t *testing.T) {
+	var tests = []struct {
+		env  map[string]string
+		want *sampler
+	}{
+		{nil, &sampler{rate: 1}},
+		{map[string]string{sfxTraceSampleRate: "0.25", sfxTraceHonorUpstream: "true", sfxTraceSampleErrors: "true", sfxTraceSlowMs: "100"},
+			&sampler{rate: 0.25, honorUpstream: true, sampleErrors: true, slowThreshold: 100 * time.Millisecond}},
+		{map[string]string{sfxTraceSampleRate: "1.5", sfxTraceSlowMs: "-1"}, &sampler{rate: 1}},
+		{map[string]string{sfxTraceSampleRate: "-0.5"}, &sampler{rate: 1}},
+	}
+	for i, test := range tests {
+		config, _ := configFromEnv(func(name string) string {
+			return test.env[name]
+		})
+		got := newSampler(config)
+		if got.rate != test.want.rate || got.honorUpstream != test.want.honorUpstream || got.honorXRay != test.want.honorXRay ||
+			got.sampleErrors != test.want.sampleErrors || got.slowThreshold != test.want.slowThreshold || got.limiter != nil {
+			t.Errorf("test %d. want sampler %+v got %+v", i, test.want, got)
+		}
+	}
+	config, _ := configFromEnv(func(name string) string {
+		return map[string]string{sfxTraceRateLimit: "10"}[name]
+	})
+	if newSampler(config).limiter == nil {
+		t.Errorf("want rate limiter")
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	s := &sampler{rate: 0.5}
+	var wg sync.WaitGroup
+	results := make([]bool, 1000)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = s.headDecision(context.TODO(), nil)
+		}(i)
+	}
+	wg.Wait()
+	sampled := 0
+	for _, r := range results {
+		if r {
+			sampled++
+		}
+	}
+	if sampled < 350 || sampled > 650 {
+		t.Errorf("want about half of 1000 invocations sampled got %d", sampled)
+	}
+}
@@ -0,0 +1,314 @@
+// Package sqlinstrument wraps database/sql drivers to send query metrics and spans through the SignalFx Go Lambda
+// Wrapper. Queries must be made with the context passed to the Lambda handler function, e.g. with db.QueryContext, for
+// the metrics and spans to be sent with the invocation.
+package sqlinstrument
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/lambda-go"
+	log "github.com/sirupsen/logrus"
+)
+
+var recordDatapoints = sfxlambda.RecordDatapoints
+
+// span is the part of *sfxlambda.Span used to trace queries.
+type span interface {
+	SetTag(key, value string)
+	Finish()
+}
+
+var startSpan = func(ctx context.Context, name string) span {
+	s, _ := sfxlambda.StartSpan(ctx, name)
+	return s
+}
+
+// Register wraps d with Wrap and registers the wrapped driver with database/sql under name.
+func Register(name string, d driver.Driver, dbName string) {
+	sql.Register(name, Wrap(d, dbName))
+}
+
+// Wrap returns a driver.Driver that delegates to d and records the db.query.count and db.query.errors counters and the
+// db.query.duration gauge with the db_name and operation dimensions for every query and exec, and a child span of the
+// invocation span when tracing is enabled. The operation is the lowercase first keyword of the query, e.g. select.
+func Wrap(d driver.Driver, dbName string) driver.Driver {
+	return &instrumentedDriver{Driver: d, dbName: dbName}
+}
+
+type instrumentedDriver struct {
+	driver.Driver
+	dbName string
+}
+
+// Open is instrumentedDriver's driver.Driver implementation.
+func (d *instrumentedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, dbName: d.dbName}, nil
+}
+
+type conn struct {
+	driver.Conn
+	dbName string
+}
+
+// Prepare is conn's driver.Conn implementation.
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext is conn's driver.ConnPrepareContext implementation.
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if cp, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = cp.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	wrapped := &stmt{Stmt: s, conn: c.Conn, query: query, dbName: c.dbName}
+	if _, ok := s.(driver.ColumnConverter); ok {
+		return &columnConverterStmt{stmt: wrapped}, nil
+	}
+	return wrapped, nil
+}
+
+// BeginTx is conn's driver.ConnBeginTx implementation. Like database/sql, it returns an error for non-default options
+// if the wrapped driver.Conn does not support them.
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if cb, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return cb.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+	return c.Conn.Begin()
+}
+
+// ExecContext is conn's driver.ExecerContext implementation. It returns driver.ErrSkip if the wrapped driver.Conn
+// cannot execute queries without preparing them.
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	var exec func() (driver.Result, error)
+	switch e := c.Conn.(type) {
+	case driver.ExecerContext:
+		exec = func() (driver.Result, error) { return e.ExecContext(ctx, query, args) }
+	case driver.Execer:
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, driver.ErrSkip
+		}
+		exec = func() (driver.Result, error) { return e.Exec(query, values) }
+	default:
+		return nil, driver.ErrSkip
+	}
+	var result driver.Result
+	err := record(ctx, c.dbName, query, func() (err error) {
+		result, err = exec()
+		return err
+	})
+	return result, err
+}
+
+// QueryContext is conn's driver.QueryerContext implementation. It returns driver.ErrSkip if the wrapped driver.Conn
+// cannot execute queries without preparing them.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var q func() (driver.Rows, error)
+	switch e := c.Conn.(type) {
+	case driver.QueryerContext:
+		q = func() (driver.Rows, error) { return e.QueryContext(ctx, query, args) }
+	case driver.Queryer:
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, driver.ErrSkip
+		}
+		q = func() (driver.Rows, error) { return e.Query(query, values) }
+	default:
+		return nil, driver.ErrSkip
+	}
+	var rows driver.Rows
+	err := record(ctx, c.dbName, query, func() (err error) {
+		rows, err = q()
+		return err
+	})
+	return rows, err
+}
+
+// Ping is conn's driver.Pinger implementation.
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession is conn's driver.SessionResetter implementation.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue is conn's driver.NamedValueChecker implementation. It returns driver.ErrSkip for the default
+// conversion if the wrapped driver.Conn has no custom conversion.
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type stmt struct {
+	driver.Stmt
+	conn   driver.Conn
+	query  string
+	dbName string
+}
+
+// columnConverterStmt is a stmt whose wrapped driver.Stmt is a driver.ColumnConverter. database/sql only uses the
+// column converter of statements implementing the interface, so stmt cannot implement it unconditionally.
+type columnConverterStmt struct {
+	*stmt
+}
+
+// ColumnConverter is columnConverterStmt's driver.ColumnConverter implementation.
+func (s *columnConverterStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.Stmt.(driver.ColumnConverter).ColumnConverter(idx)
+}
+
+// CheckNamedValue is stmt's driver.NamedValueChecker implementation. As database/sql only falls back to the
+// driver.NamedValueChecker of the connection for statements that do not implement it, it uses the one of the wrapped
+// driver.Stmt, else the one of the wrapped driver.Conn, else returns driver.ErrSkip for the default conversion.
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	if n, ok := s.conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// Exec is stmt's driver.Stmt implementation.
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	var result driver.Result
+	err := record(context.Background(), s.dbName, s.query, func() (err error) {
+		result, err = s.Stmt.Exec(args)
+		return err
+	})
+	return result, err
+}
+
+// Query is stmt's driver.Stmt implementation.
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	var rows driver.Rows
+	err := record(context.Background(), s.dbName, s.query, func() (err error) {
+		rows, err = s.Stmt.Query(args)
+		return err
+	})
+	return rows, err
+}
+
+// ExecContext is stmt's driver.StmtExecContext implementation.
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	err := record(ctx, s.dbName, s.query, func() (err error) {
+		if se, ok := s.Stmt.(driver.StmtExecContext); ok {
+			result, err = se.ExecContext(ctx, args)
+			return err
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		result, err = s.Stmt.Exec(values)
+		return err
+	})
+	return result, err
+}
+
+// QueryContext is stmt's driver.StmtQueryContext implementation.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	err := record(ctx, s.dbName, s.query, func() (err error) {
+		if sq, ok := s.Stmt.(driver.StmtQueryContext); ok {
+			rows, err = sq.QueryContext(ctx, args)
+			return err
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		rows, err = s.Stmt.Query(values)
+		return err
+	})
+	return rows, err
+}
+
+// record runs f inside a child span of the span in ctx and records the query datapoints of f. If f returns
+// driver.ErrSkip no query ran, the span is finished without datapoints and database/sql retries another way.
+func record(ctx context.Context, dbName, query string, f func() error) error {
+	op := operation(query)
+	span := startSpan(ctx, op)
+	span.SetTag("db.instance", dbName)
+	span.SetTag("db.statement", query)
+	start := time.Now()
+	err := f()
+	elapsed := time.Since(start)
+	if err == driver.ErrSkip {
+		span.Finish()
+		return err
+	}
+	if err != nil {
+		span.SetTag("error", "true")
+		span.SetTag("error.message", err.Error())
+	}
+	span.Finish()
+	dims := map[string]string{"db_name": dbName, "operation": op}
+	dps := []*datapoint.Datapoint{
+		{Metric: "db.query.count", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter, Dimensions: dims},
+		{Metric: "db.query.duration", Value: datapoint.NewIntValue(sfxlambda.Milliseconds(elapsed)), MetricType: datapoint.Gauge, Dimensions: dims},
+	}
+	if err != nil {
+		dps = append(dps, &datapoint.Datapoint{Metric: "db.query.errors", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter, Dimensions: dims})
+	}
+	if err2 := recordDatapoints(ctx, dps); err2 != nil {
+		log.Error(err2)
+	}
+	return err
+}
+
+// operation returns the lowercase first keyword of query.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return strings.ToLower(strings.TrimLeft(fields[0], "("))
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("named parameter %s not supported by driver", arg.Name)
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,263 @@
+package sqlinstrument
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	switch {
+	case strings.HasPrefix(query, "convert"):
+		return convertingStmt{fakeStmt{query: query}}, nil
+	case strings.HasPrefix(query, "check"):
+		return checkingStmt{fakeStmt{query: query}}, nil
+	}
+	return fakeStmt{query: query}, nil
+}
+func (fakeConn) Close() error              { return nil }
+func (fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+// skippingDriver opens connections whose ExecContext returns driver.ErrSkip.
+type skippingDriver struct{}
+
+func (skippingDriver) Open(string) (driver.Conn, error) { return skippingConn{}, nil }
+
+type skippingConn struct {
+	fakeConn
+}
+
+func (skippingConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+// execArgs are the arguments of the last executed fakeStmt.
+var execArgs []driver.Value
+
+type fakeStmt struct {
+	query string
+}
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	execArgs = args
+	if s.query == "bad" {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(1), nil
+}
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) { return fakeRows{}, nil }
+
+// convertingStmt converts every argument to the string converted.
+type convertingStmt struct {
+	fakeStmt
+}
+
+func (convertingStmt) ColumnConverter(int) driver.ValueConverter {
+	return constantConverter("converted")
+}
+
+type constantConverter string
+
+func (c constantConverter) ConvertValue(interface{}) (driver.Value, error) { return string(c), nil }
+
+// checkingStmt converts every argument to the string checked.
+type checkingStmt struct {
+	fakeStmt
+}
+
+func (checkingStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	nv.Value = "checked"
+	return nil
+}
+
+// fakeSpan records the tags of a span.
+type fakeSpan struct {
+	name     string
+	tags     map[string]string
+	finished bool
+}
+
+func (s *fakeSpan) SetTag(key, value string) { s.tags[key] = value }
+func (s *fakeSpan) Finish()                  { s.finished = true }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"id"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+func TestWrap(t *testing.T) {
+	Register("sqlinstrument-fake", fakeDriver{}, "db1")
+	db, err := sql.Open("sqlinstrument-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	savedRecordDatapoints := recordDatapoints
+	defer func() {
+		recordDatapoints = savedRecordDatapoints
+	}()
+	savedStartSpan := startSpan
+	defer func() {
+		startSpan = savedStartSpan
+	}()
+	var got []*datapoint.Datapoint
+	recordDatapoints = func(_ context.Context, dps []*datapoint.Datapoint) error {
+		got = append(got, dps...)
+		return nil
+	}
+	var spans []*fakeSpan
+	startSpan = func(_ context.Context, name string) span {
+		s := &fakeSpan{name: name, tags: map[string]string{}}
+		spans = append(spans, s)
+		return s
+	}
+	ctx := context.TODO()
+	if _, err := db.ExecContext(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.QueryContext(ctx, "select id from t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "bad"); err == nil {
+		t.Errorf("want syntax error")
+	}
+	counts := map[string]int{}
+	for _, dp := range got {
+		switch dp.Metric {
+		case "db.query.count", "db.query.errors":
+			if dp.Dimensions["db_name"] != "db1" {
+				t.Errorf("want db_name db1 got %+v", dp.Dimensions)
+			}
+			counts[dp.Metric+" "+dp.Dimensions["operation"]]++
+		}
+	}
+	want := map[string]int{"db.query.count insert": 1, "db.query.count select": 1, "db.query.count bad": 1, "db.query.errors bad": 1}
+	for k, v := range want {
+		if counts[k] != v {
+			t.Errorf("want %d %s datapoints got %d", v, k, counts[k])
+		}
+	}
+	wantSpans := []*fakeSpan{
+		{"insert", map[string]string{"db.instance": "db1", "db.statement": "INSERT INTO t VALUES (1)"}, true},
+		{"select", map[string]string{"db.instance": "db1", "db.statement": "select id from t"}, true},
+		{"bad", map[string]string{"db.instance": "db1", "db.statement": "bad", "error": "true", "error.message": "syntax error"}, true},
+	}
+	if !reflect.DeepEqual(spans, wantSpans) {
+		t.Errorf("want spans %+v got %+v", wantSpans, spans)
+	}
+}
+
+func TestWrapConversion(t *testing.T) {
+	Register("sqlinstrument-fake-conversion", fakeDriver{}, "db1")
+	db, err := sql.Open("sqlinstrument-fake-conversion", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var tests = []struct {
+		query string
+		want  driver.Value
+	}{
+		{"insert", int64(1)},
+		{"convert", "converted"},
+		{"check", "checked"},
+	}
+	for _, test := range tests {
+		if _, err := db.ExecContext(context.TODO(), test.query, 1); err != nil {
+			t.Fatal(err)
+		}
+		if want := []driver.Value{test.want}; !reflect.DeepEqual(execArgs, want) {
+			t.Errorf("%s: want args %v got %v", test.query, want, execArgs)
+		}
+	}
+}
+
+func TestBeginTxOptions(t *testing.T) {
+	Register("sqlinstrument-fake-tx", fakeDriver{}, "db1")
+	db, err := sql.Open("sqlinstrument-fake-tx", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var tests = []struct {
+		opts    *sql.TxOptions
+		wantErr bool
+	}{
+		{nil, false},
+		{&sql.TxOptions{ReadOnly: true}, true},
+		{&sql.TxOptions{Isolation: sql.LevelSerializable}, true},
+	}
+	for _, test := range tests {
+		tx, err := db.BeginTx(context.TODO(), test.opts)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%+v: want error %v got %v", test.opts, test.wantErr, err)
+		}
+		if tx != nil {
+			tx.Rollback()
+		}
+	}
+}
+
+func TestWrapSkip(t *testing.T) {
+	Register("sqlinstrument-fake-skip", skippingDriver{}, "db1")
+	db, err := sql.Open("sqlinstrument-fake-skip", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	savedRecordDatapoints, savedStartSpan := recordDatapoints, startSpan
+	defer func() {
+		recordDatapoints, startSpan = savedRecordDatapoints, savedStartSpan
+	}()
+	counts := 0
+	recordDatapoints = func(_ context.Context, dps []*datapoint.Datapoint) error {
+		for _, dp := range dps {
+			if dp.Metric == "db.query.count" {
+				counts++
+			}
+		}
+		return nil
+	}
+	var spans []*fakeSpan
+	startSpan = func(_ context.Context, name string) span {
+		s := &fakeSpan{name: name, tags: map[string]string{}}
+		spans = append(spans, s)
+		return s
+	}
+	if _, err := db.ExecContext(context.TODO(), "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if counts != 1 {
+		t.Errorf("want 1 db.query.count datapoint for the prepared statement fallback got %d", counts)
+	}
+	for _, s := range spans {
+		if !s.finished {
+			t.Errorf("want all spans finished got %+v", s)
+		}
+	}
+}
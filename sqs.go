@@ -0,0 +1,76 @@
+package sfxlambda
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+// sqsAttributes holds the SQS message attributes of an SQS event record.
+type sqsAttributes struct {
+	ApproximateReceiveCount string `json:"ApproximateReceiveCount"`
+	SentTimestamp           string `json:"SentTimestamp"`
+}
+
+// sqsMaxReceiveCountBucket is the receive count bucket of the sqs.records_by_receive_count counter that counts all
+// records received this many times or more.
+const sqsMaxReceiveCountBucket = 5
+
+// sqsDatapoints returns per queue the sqs.records_received counter, the sqs.message_age gauge of the oldest message in
+// milliseconds and the sqs.records_by_receive_count counter dimensioned by approximate_receive_count.
+func sqsDatapoints(records []eventRecord, now time.Time) []*datapoint.Datapoint {
+	type queueStats struct {
+		received      int64
+		maxAge        int64
+		hasAge        bool
+		receiveCounts map[string]int64
+	}
+	queues := map[string]*queueStats{}
+	var names []string
+	for _, r := range records {
+		if r.EventSource != "aws:sqs" {
+			continue
+		}
+		name := sqsQueueName(r.EventSourceARN)
+		q, ok := queues[name]
+		if !ok {
+			q = &queueStats{receiveCounts: map[string]int64{}}
+			queues[name] = q
+			names = append(names, name)
+		}
+		q.received++
+		if sent, err := strconv.ParseInt(r.Attributes.SentTimestamp, 10, 64); err == nil {
+			if age := Milliseconds(now.Sub(time.Unix(0, sent*int64(time.Millisecond)))); !q.hasAge || age > q.maxAge {
+				q.maxAge, q.hasAge = age, true
+			}
+		}
+		if count, err := strconv.Atoi(r.Attributes.ApproximateReceiveCount); err == nil {
+			bucket := strconv.Itoa(count)
+			if count >= sqsMaxReceiveCountBucket {
+				bucket = strconv.Itoa(sqsMaxReceiveCountBucket) + "+"
+			}
+			q.receiveCounts[bucket]++
+		}
+	}
+	var dps []*datapoint.Datapoint
+	for _, name := range names {
+		q := queues[name]
+		dims := map[string]string{"queue_name": name}
+		dps = append(dps, &datapoint.Datapoint{Metric: "sqs.records_received", Value: datapoint.NewIntValue(q.received), MetricType: datapoint.Counter, Dimensions: dims})
+		if q.hasAge {
+			dps = append(dps, &datapoint.Datapoint{Metric: "sqs.message_age", Value: datapoint.NewIntValue(q.maxAge), MetricType: datapoint.Gauge, Dimensions: dims})
+		}
+		for bucket, count := range q.receiveCounts {
+			dps = append(dps, &datapoint.Datapoint{Metric: "sqs.records_by_receive_count", Value: datapoint.NewIntValue(count), MetricType: datapoint.Counter,
+				Dimensions: datapoint.AddMaps(dims, map[string]string{"approximate_receive_count": bucket})})
+		}
+	}
+	return dps
+}
+
+// sqsQueueName returns the queue name of an SQS queue ARN of the format arn:aws:sqs:region:account-id:queue-name.
+func sqsQueueName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
@@ -0,0 +1,69 @@
+package sfxlambda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+const sqsEvent = `{
+  "Records": [
+    {
+      "messageId": "059f36b4-87a3-44ab-83d2-661975830a7d",
+      "body": "test",
+      "attributes": {
+        "ApproximateReceiveCount": "1",
+        "SentTimestamp": "1545082649183"
+      },
+      "eventSource": "aws:sqs",
+      "eventSourceARN": "arn:aws:sqs:us-east-2:123456789012:my-queue",
+      "awsRegion": "us-east-2"
+    },
+    {
+      "messageId": "2e1424d4-f796-459a-8184-9c92662be6da",
+      "body": "test",
+      "attributes": {
+        "ApproximateReceiveCount": "7",
+        "SentTimestamp": "1545082650636"
+      },
+      "eventSource": "aws:sqs",
+      "eventSourceARN": "arn:aws:sqs:us-east-2:123456789012:my-queue",
+      "awsRegion": "us-east-2"
+    }
+  ]
+}`
+
+func TestSQSDatapoints(t *testing.T) {
+	now := time.Unix(0, 1545082659183*int64(time.Millisecond))
+	dps := eventDatapoints(parseEventPayload([]byte(sqsEvent)), now)
+	got := map[string]int64{}
+	for _, dp := range dps {
+		if dp.Dimensions["queue_name"] != "my-queue" {
+			t.Errorf("want queue_name my-queue got %+v", dp.Dimensions)
+		}
+		got[dp.Metric+" "+dp.Dimensions["approximate_receive_count"]] = dp.Value.(datapoint.IntValue).Int()
+	}
+	want := map[string]int64{
+		"sqs.records_received ":           2,
+		"sqs.message_age ":                10000,
+		"sqs.records_by_receive_count 1":  1,
+		"sqs.records_by_receive_count 5+": 1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("want %+v got %+v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("want %s %d got %d", k, v, got[k])
+		}
+	}
+}
+
+func TestNonEventPayloadDatapoints(t *testing.T) {
+	for _, payload := range []string{`""`, `{}`, `{"Records":[{"eventSource":"aws:unknown"}]}`, `[1,2]`} {
+		if dps := eventDatapoints(parseEventPayload([]byte(payload)), time.Now()); len(dps) != 0 {
+			t.Errorf("want no datapoints for payload %s got %+v", payload, dps)
+		}
+	}
+}
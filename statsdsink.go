@@ -0,0 +1,160 @@
+package sfxlambda
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+const (
+	defaultStatsdAddress       = "udp://127.0.0.1:8125"
+	defaultStatsdMaxPacketSize = 1432
+
+	statsdCounterType = "c"
+	statsdGaugeType   = "g"
+	statsdTimingType  = "ms"
+)
+
+// statsdSink is a sfxclient.Sink sending datapoints as DogStatsD lines over UDP or Unix datagram sockets, e.g. to a
+// local agent. Dimensions are sent as tags. Counters and cumulative counters are sent as counters, the duration metrics
+// as timings and other gauges as gauges. Lines are batched into packets of at most maxPacketSize bytes.
+type statsdSink struct {
+	mu            sync.Mutex
+	network       string
+	address       string
+	maxPacketSize int
+	conn          net.Conn
+}
+
+// newStatsdSink returns a statsdSink sending to address, an URL of scheme udp or unixgram, e.g. udp://127.0.0.1:8125 or
+// unixgram:///var/run/datadog/dsd.socket. The socket is connected on first use. A maxPacketSize that is not positive is
+// the default of 1432 bytes, fitting UDP packets into the usual Ethernet MTU.
+func newStatsdSink(address string, maxPacketSize int) (*statsdSink, error) {
+	if maxPacketSize <= 0 {
+		maxPacketSize = defaultStatsdMaxPacketSize
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing statsd address %s. %+v", address, err)
+	}
+	s := &statsdSink{network: u.Scheme, maxPacketSize: maxPacketSize}
+	switch u.Scheme {
+	case "udp":
+		s.address = u.Host
+	case "unixgram":
+		s.address = u.Path
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s of statsd address %s, want udp or unixgram", u.Scheme, address)
+	}
+	if s.address == "" {
+		return nil, fmt.Errorf("no host or path in statsd address %s", address)
+	}
+	return s, nil
+}
+
+// AddDatapoints is statsdSink's sfxclient.Sink implementation. Datapoints with non numeric values are skipped and
+// reported in the returned error. The connection is closed on write errors and dialed again by the next call.
+func (s *statsdSink) AddDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
+	var errs []error
+	var packets [][]byte
+	var packet bytes.Buffer
+	for _, dp := range dps {
+		line, err := statsdLine(dp)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if packet.Len() > 0 && packet.Len()+1+len(line) > s.maxPacketSize {
+			packets = append(packets, append([]byte(nil), packet.Bytes()...))
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		packets = append(packets, packet.Bytes())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil && len(packets) > 0 {
+		conn, err := net.Dial(s.network, s.address)
+		if err != nil {
+			return fmt.Errorf("error connecting to statsd %s %s. %+v", s.network, s.address, err)
+		}
+		s.conn = conn
+	}
+	for _, p := range packets {
+		if _, err := s.conn.Write(p); err != nil {
+			// The socket may be gone, e.g. after the agent restarted, so the next send dials again.
+			s.conn.Close()
+			s.conn = nil
+			errs = append(errs, err)
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error sending statsd datapoints. %+v", errs)
+	}
+	return nil
+}
+
+// statsdLine returns the DogStatsD line of dp, i.e. name:value|type|#tag:value,...
+func statsdLine(dp *datapoint.Datapoint) (string, error) {
+	var value string
+	switch v := dp.Value.(type) {
+	case datapoint.IntValue:
+		value = strconv.FormatInt(v.Int(), 10)
+	case datapoint.FloatValue:
+		value = strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	default:
+		return "", fmt.Errorf("unsupported value %v of metric %s", dp.Value, dp.Metric)
+	}
+	metricType := statsdGaugeType
+	switch {
+	case dp.MetricType == datapoint.Counter || dp.MetricType == datapoint.Count:
+		metricType = statsdCounterType
+	case durationMetrics[dp.Metric]:
+		metricType = statsdTimingType
+	}
+	line := statsdName(dp.Metric) + ":" + value + "|" + metricType
+	if len(dp.Dimensions) == 0 {
+		return line, nil
+	}
+	tags := make([]string, 0, len(dp.Dimensions))
+	for k, v := range dp.Dimensions {
+		tags = append(tags, statsdName(k)+":"+statsdTagValue(v))
+	}
+	sort.Strings(tags)
+	return line + "|#" + strings.Join(tags, ","), nil
+}
+
+// statsdName replaces the characters of the metric or tag name s reserved by the DogStatsD line format with
+// underscores.
+func statsdName(s string) string {
+	return statsdReplace(s, ":|@#,\n")
+}
+
+// statsdTagValue replaces the characters of the tag value s reserved by the DogStatsD line format with underscores. Tag
+// values may contain colons, e.g. ARNs.
+func statsdTagValue(s string) string {
+	return statsdReplace(s, "|,\n")
+}
+
+func statsdReplace(s, reserved string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(reserved, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,143 @@
+package sfxlambda
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+func TestStatsdSink(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	sink, err := newStatsdSink("udp://"+listener.LocalAddr().String(), 120)
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	dims := map[string]string{"aws_function_name": "my-function", "lambda_arn": "arn:aws:lambda:us-east-1:accountId:function:my-function"}
+	dps := []*datapoint.Datapoint{
+		{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter, Dimensions: dims},
+		{Metric: "function.duration", Value: datapoint.NewIntValue(30), MetricType: datapoint.Gauge},
+		{Metric: "sqs.message_age", Value: datapoint.NewFloatValue(2.5), MetricType: datapoint.Gauge},
+	}
+	if err := sink.AddDatapoints(context.TODO(), dps); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	want := []string{
+		"function.invocations:1|c|#aws_function_name:my-function,lambda_arn:arn:aws:lambda:us-east-1:accountId:function:my-function",
+		"function.duration:30|ms\nsqs.message_age:2.5|g",
+	}
+	buf := make([]byte, 2048)
+	for _, w := range want {
+		if err := listener.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("want packet got %+v", err)
+		}
+		if got := string(buf[:n]); got != w {
+			t.Errorf("want packet %q got %q", w, got)
+		}
+		if n > sink.maxPacketSize && strings.Contains(string(buf[:n]), "\n") {
+			t.Errorf("want batched packet of at most %d bytes got %d", sink.maxPacketSize, n)
+		}
+	}
+}
+
+func TestStatsdLine(t *testing.T) {
+	var tests = []struct {
+		dp   *datapoint.Datapoint
+		want string
+	}{
+		{&datapoint.Datapoint{Metric: "db.query.count", Value: datapoint.NewIntValue(2), MetricType: datapoint.Count}, "db.query.count:2|c"},
+		{&datapoint.Datapoint{Metric: "a|b", Value: datapoint.NewIntValue(1), MetricType: datapoint.Gauge,
+			Dimensions: map[string]string{"route": "/a,b", "b:c": "d"}}, "a_b:1|g|#b_c:d,route:/a_b"},
+	}
+	for _, test := range tests {
+		if got, err := statsdLine(test.dp); err != nil || got != test.want {
+			t.Errorf("want %s got %s %+v", test.want, got, err)
+		}
+	}
+	if _, err := statsdLine(&datapoint.Datapoint{Metric: "bad", Value: datapoint.NewStringValue("x")}); err == nil {
+		t.Errorf("want error for string value")
+	}
+}
+
+func TestNewStatsdSink(t *testing.T) {
+	var tests = []struct {
+		address, network, want string
+		wantErr                bool
+	}{
+		{"udp://127.0.0.1:8125", "udp", "127.0.0.1:8125", false},
+		{"unixgram:///var/run/datadog/dsd.socket", "unixgram", "/var/run/datadog/dsd.socket", false},
+		{"tcp://127.0.0.1:8125", "", "", true},
+		{"udp://", "", "", true},
+	}
+	for _, test := range tests {
+		sink, err := newStatsdSink(test.address, 0)
+		if (err != nil) != test.wantErr {
+			t.Errorf("address %s. want error %t got %+v", test.address, test.wantErr, err)
+			continue
+		}
+		if err == nil && (sink.network != test.network || sink.address != test.want || sink.maxPacketSize != defaultStatsdMaxPacketSize) {
+			t.Errorf("address %s. want %s %s got %s %s", test.address, test.network, test.want, sink.network, sink.address)
+		}
+	}
+}
+
+func TestStatsdSinkRedial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statsd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dsd.socket")
+	listener, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := newStatsdSink("unixgram://"+path, 0)
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	dps := []*datapoint.Datapoint{{Metric: "function.invocations", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter}}
+	if err := sink.AddDatapoints(context.TODO(), dps); err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	// Simulates an agent restart, which removes and binds the socket again.
+	listener.Close()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.AddDatapoints(context.TODO(), dps); err == nil {
+		t.Fatalf("want error sending to closed socket")
+	}
+	if sink.conn != nil {
+		t.Errorf("want connection closed after write error")
+	}
+	listener, err = net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := sink.AddDatapoints(context.TODO(), dps); err != nil {
+		t.Fatalf("want no error after redial got %+v", err)
+	}
+	buf := make([]byte, 2048)
+	if err := listener.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _, err := listener.ReadFrom(buf); err != nil || string(buf[:n]) != "function.invocations:1|c" {
+		t.Errorf("want packet after redial got %q %+v", buf[:n], err)
+	}
+}
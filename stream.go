@@ -0,0 +1,99 @@
+package sfxlambda
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+// kinesisRecord holds the Kinesis data of a Kinesis event record.
+type kinesisRecord struct {
+	ApproximateArrivalTimestamp float64 `json:"approximateArrivalTimestamp"`
+}
+
+// dynamoDBRecord holds the stream record of a DynamoDB Streams event record.
+type dynamoDBRecord struct {
+	ApproximateCreationDateTime float64 `json:"ApproximateCreationDateTime"`
+}
+
+// streamDatapoints returns per stream and shard the stream.records counter, the stream.batch_size gauge and the
+// stream.iterator_age_ms gauge, the age of the oldest record of the batch in milliseconds. DynamoDB Streams records do
+// not carry their shard so their datapoints have no shard dimension.
+func streamDatapoints(records []eventRecord, now time.Time) []*datapoint.Datapoint {
+	type shardKey struct {
+		stream string
+		shard  string
+	}
+	type shardStats struct {
+		records int64
+		maxAge  int64
+		hasAge  bool
+	}
+	shards := map[shardKey]*shardStats{}
+	var keys []shardKey
+	for _, r := range records {
+		var key shardKey
+		var arrival float64
+		switch r.EventSource {
+		case "aws:kinesis":
+			key = shardKey{stream: streamName(r.EventSourceARN, "stream/"), shard: kinesisShardID(r.EventID)}
+			arrival = r.Kinesis.ApproximateArrivalTimestamp
+		case "aws:dynamodb":
+			key = shardKey{stream: streamName(r.EventSourceARN, "table/")}
+			arrival = r.DynamoDB.ApproximateCreationDateTime
+		default:
+			continue
+		}
+		s, ok := shards[key]
+		if !ok {
+			s = &shardStats{}
+			shards[key] = s
+			keys = append(keys, key)
+		}
+		s.records++
+		if arrival > 0 {
+			if age := Milliseconds(now.Sub(time.Unix(0, int64(math.Round(arrival*1e3))*int64(time.Millisecond)))); !s.hasAge || age > s.maxAge {
+				s.maxAge, s.hasAge = age, true
+			}
+		}
+	}
+	var dps []*datapoint.Datapoint
+	for _, key := range keys {
+		s := shards[key]
+		dims := map[string]string{"stream_name": key.stream}
+		if key.shard != "" {
+			dims["shard"] = key.shard
+		}
+		dps = append(dps,
+			&datapoint.Datapoint{Metric: "stream.records", Value: datapoint.NewIntValue(s.records), MetricType: datapoint.Counter, Dimensions: dims},
+			&datapoint.Datapoint{Metric: "stream.batch_size", Value: datapoint.NewIntValue(s.records), MetricType: datapoint.Gauge, Dimensions: dims})
+		if s.hasAge {
+			dps = append(dps, &datapoint.Datapoint{Metric: "stream.iterator_age_ms", Value: datapoint.NewIntValue(s.maxAge), MetricType: datapoint.Gauge, Dimensions: dims})
+		}
+	}
+	return dps
+}
+
+// streamName returns the resource name following prefix in a Kinesis stream ARN
+// (arn:aws:kinesis:region:account-id:stream/stream-name) or a DynamoDB stream ARN
+// (arn:aws:dynamodb:region:account-id:table/table-name/stream/label).
+func streamName(arn, prefix string) string {
+	resource := arn[strings.LastIndex(arn, ":")+1:]
+	if i := strings.Index(arn, ":"+prefix); i >= 0 {
+		resource = arn[i+1+len(prefix):]
+	}
+	if i := strings.Index(resource, "/"); i >= 0 {
+		resource = resource[:i]
+	}
+	return resource
+}
+
+// kinesisShardID returns the shard ID of a Kinesis event ID of the format shardId-000000000006:sequence-number.
+func kinesisShardID(eventID string) string {
+	if i := strings.Index(eventID, ":"); i >= 0 {
+		return eventID[:i]
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package sfxlambda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+const kinesisEvent = `{
+  "Records": [
+    {
+      "kinesis": {
+        "partitionKey": "1",
+        "sequenceNumber": "49590338271490256608559692538361571095921575989136588898",
+        "data": "SGVsbG8sIHRoaXMgaXMgYSB0ZXN0Lg==",
+        "approximateArrivalTimestamp": 1545084650.987
+      },
+      "eventSource": "aws:kinesis",
+      "eventID": "shardId-000000000006:49590338271490256608559692538361571095921575989136588898",
+      "eventSourceARN": "arn:aws:kinesis:us-east-2:123456789012:stream/lambda-stream"
+    },
+    {
+      "kinesis": {
+        "partitionKey": "1",
+        "sequenceNumber": "49590338271490256608559692540925702759324208523137515618",
+        "data": "VGhpcyBpcyBvbmx5IGEgdGVzdC4=",
+        "approximateArrivalTimestamp": 1545084711.166
+      },
+      "eventSource": "aws:kinesis",
+      "eventID": "shardId-000000000006:49590338271490256608559692540925702759324208523137515618",
+      "eventSourceARN": "arn:aws:kinesis:us-east-2:123456789012:stream/lambda-stream"
+    }
+  ]
+}`
+
+const dynamoDBEvent = `{
+  "Records": [
+    {
+      "eventID": "1",
+      "eventName": "INSERT",
+      "dynamodb": {
+        "Keys": {"Id": {"N": "101"}},
+        "ApproximateCreationDateTime": 1479499740,
+        "SequenceNumber": "111",
+        "StreamViewType": "NEW_AND_OLD_IMAGES"
+      },
+      "eventSource": "aws:dynamodb",
+      "eventSourceARN": "arn:aws:dynamodb:us-east-2:123456789012:table/my-table/stream/2016-11-16T20:42:48.104"
+    }
+  ]
+}`
+
+func TestStreamDatapoints(t *testing.T) {
+	var tests = []struct {
+		payload  string
+		now      time.Time
+		wantDims map[string]string
+		want     map[string]int64
+	}{
+		{kinesisEvent,
+			time.Unix(1545084660, 987*int64(time.Millisecond)),
+			map[string]string{"stream_name": "lambda-stream", "shard": "shardId-000000000006"},
+			map[string]int64{"stream.records": 2, "stream.batch_size": 2, "stream.iterator_age_ms": 10000},
+		},
+		{dynamoDBEvent,
+			time.Unix(1479499745, 0),
+			map[string]string{"stream_name": "my-table"},
+			map[string]int64{"stream.records": 1, "stream.batch_size": 1, "stream.iterator_age_ms": 5000},
+		},
+	}
+	for _, test := range tests {
+		dps := eventDatapoints(parseEventPayload([]byte(test.payload)), test.now)
+		if len(dps) != len(test.want) {
+			t.Errorf("want %d datapoints got %d", len(test.want), len(dps))
+		}
+		for _, dp := range dps {
+			if got := dp.Value.(datapoint.IntValue).Int(); got != test.want[dp.Metric] {
+				t.Errorf("want %s %d got %d", dp.Metric, test.want[dp.Metric], got)
+			}
+			if len(dp.Dimensions) != len(test.wantDims) {
+				t.Errorf("want dimensions %+v got %+v", test.wantDims, dp.Dimensions)
+			}
+			for k, v := range test.wantDims {
+				if dp.Dimensions[k] != v {
+					t.Errorf("want dimension %s %s got %s", k, v, dp.Dimensions[k])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package sfxlambda
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/signalfx/golib/sfxclient"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultTokenRefresh = 5 * time.Minute
+
+	envTokenSource            = "env"
+	fileTokenSource           = "file"
+	ssmTokenSource            = "ssm"
+	secretsManagerTokenSource = "secretsmanager"
+)
+
+// TokenProvider provides the SignalFx authentication token. See SetTokenProvider.
+type TokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenProviderFunc is a TokenProvider implementation calling the function itself.
+type TokenProviderFunc func(ctx context.Context) (string, error)
+
+// Token is TokenProviderFunc's TokenProvider implementation.
+func (f TokenProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// envTokenProvider provides the token of the environment variable name.
+type envTokenProvider struct {
+	name string
+}
+
+func (p *envTokenProvider) Token(context.Context) (string, error) {
+	token := strings.TrimSpace(os.Getenv(p.name))
+	if token == "" {
+		return "", fmt.Errorf("no value for environment variable %s", p.name)
+	}
+	return token, nil
+}
+
+// fileTokenProvider provides the token stored in the file at path.
+type fileTokenProvider struct {
+	path string
+}
+
+func (p *fileTokenProvider) Token(context.Context) (string, error) {
+	b, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token file %s. %+v", p.path, err)
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("empty token file %s", p.path)
+	}
+	return token, nil
+}
+
+// ssmTokenProvider provides the token stored as the SSM Parameter Store parameter name, e.g. a SecureString parameter
+// decrypted with the KMS key of the parameter.
+type ssmTokenProvider struct {
+	api  awsAPI
+	name string
+}
+
+func (p *ssmTokenProvider) Token(ctx context.Context) (string, error) {
+	in := map[string]interface{}{"Name": p.name, "WithDecryption": true}
+	var out struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}
+	if err := p.api.call(ctx, "ssm", "AmazonSSM.GetParameter", in, &out); err != nil {
+		return "", err
+	}
+	if out.Parameter.Value == "" {
+		return "", fmt.Errorf("empty value of ssm parameter %s", p.name)
+	}
+	return out.Parameter.Value, nil
+}
+
+// secretsManagerTokenProvider provides the token stored as the Secrets Manager secret secretID. If key is set the
+// secret is a JSON object and the token is the value of key.
+type secretsManagerTokenProvider struct {
+	api      awsAPI
+	secretID string
+	key      string
+}
+
+func (p *secretsManagerTokenProvider) Token(ctx context.Context) (string, error) {
+	in := map[string]interface{}{"SecretId": p.secretID}
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := p.api.call(ctx, "secretsmanager", "secretsmanager.GetSecretValue", in, &out); err != nil {
+		return "", err
+	}
+	token := out.SecretString
+	if p.key != "" {
+		var secret map[string]string
+		if err := json.Unmarshal([]byte(token), &secret); err != nil {
+			return "", fmt.Errorf("error parsing secret %s as json object. %+v", p.secretID, err)
+		}
+		token = secret[p.key]
+	}
+	if token == "" {
+		return "", fmt.Errorf("empty value of secret %s", p.secretID)
+	}
+	return token, nil
+}
+
+// cachingTokenProvider caches the token of provider for refresh. When refreshing fails the cached token is used until
+// the next refresh attempt. The token is fetched without holding mu, concurrent callers wait for the fetch in flight.
+type cachingTokenProvider struct {
+	provider TokenProvider
+	refresh  time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	token    string
+	fetched  time.Time
+	inFlight *tokenFetch
+}
+
+// tokenFetch is a token fetch of cachingTokenProvider, done when the token and err are set.
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func newCachingTokenProvider(provider TokenProvider, refresh time.Duration) *cachingTokenProvider {
+	return &cachingTokenProvider{provider: provider, refresh: refresh, now: time.Now}
+}
+
+func (p *cachingTokenProvider) Token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	now := p.now()
+	if p.token != "" && (p.refresh <= 0 || now.Sub(p.fetched) < p.refresh) {
+		defer p.mu.Unlock()
+		return p.token, nil
+	}
+	if f := p.inFlight; f != nil {
+		p.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.token, f.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	f := &tokenFetch{done: make(chan struct{})}
+	p.inFlight = f
+	p.mu.Unlock()
+	defer close(f.done)
+
+	f.token, f.err = p.provider.Token(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight = nil
+	if f.err != nil {
+		if p.token == "" {
+			return "", f.err
+		}
+		log.Errorf("error refreshing SignalFx auth token, using cached token. %+v", f.err)
+		f.token, f.err = p.token, nil
+	}
+	p.token, p.fetched = f.token, now
+	return f.token, nil
+}
+
+// parseTokenProvider returns the TokenProvider of the token source value, one of env, file:<path>,
+// ssm:<parameter name> and secretsmanager:<secret id>[#<json key>].
+func parseTokenProvider(value string, client *http.Client) (TokenProvider, error) {
+	source, arg := value, ""
+	if i := strings.Index(value, ":"); i >= 0 {
+		source, arg = value[:i], value[i+1:]
+	}
+	if source != envTokenSource && arg == "" {
+		return nil, fmt.Errorf("no argument for token source %s", source)
+	}
+	switch source {
+	case envTokenSource:
+		return &envTokenProvider{name: sfxAuthToken}, nil
+	case fileTokenSource:
+		return &fileTokenProvider{path: arg}, nil
+	case ssmTokenSource:
+		return &ssmTokenProvider{api: newAWSJSONClient(client), name: arg}, nil
+	case secretsManagerTokenSource:
+		p := &secretsManagerTokenProvider{api: newAWSJSONClient(client), secretID: arg}
+		if i := strings.LastIndex(arg, "#"); i >= 0 {
+			p.secretID, p.key = arg[:i], arg[i+1:]
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown token source %s", source)
+}
+
+// tokenTransport is an http.RoundTripper setting the SignalFx auth token header of the requests without one to the
+// token of provider. The token is therefore resolved on the first send.
+type tokenTransport struct {
+	base     http.RoundTripper
+	provider TokenProvider
+}
+
+// RoundTrip is tokenTransport's http.RoundTripper implementation.
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(sfxclient.TokenHeaderName) == "" {
+		token, err := t.provider.Token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("error getting SignalFx auth token. %+v", err)
+		}
+		req = cloneRequestHeader(req)
+		req.Header.Set(sfxclient.TokenHeaderName, token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// SetTokenProvider sets the provider of the SignalFx authentication token used to send datapoints, events and spans in
+// place of SIGNALFX_AUTH_TOKEN. The token is requested on every send, so provider should cache it. It must
+// be called before the wrapped handler is invoked.
+func SetTokenProvider(provider TokenProvider) {
+	// The client of the sink is replaced rather than modified so that the token is not sent by the other sinks created
+	// with the previous client, e.g. to an OpenTelemetry collector.
+	client := *handlerFuncWrapperClient.Client
+	if t, ok := client.Transport.(*tokenTransport); ok {
+		client.Transport = t.base
+	}
+	if client.Transport == nil {
+		client.Transport = http.DefaultTransport
+	}
+	client.Transport = &tokenTransport{base: client.Transport, provider: provider}
+	handlerFuncWrapperClient.AuthToken = ""
+	handlerFuncWrapperClient.Client = &client
+}
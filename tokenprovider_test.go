@@ -0,0 +1,224 @@
+package sfxlambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/signalfx/golib/sfxclient"
+)
+
+// awsStandIn returns a local stand-in of the AWS JSON APIs answering operation targets with responses.
+func awsStandIn(responses map[string]string) (*httptest.Server, *awsJSONClient) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), sigV4Algorithm+" Credential=AKID/") || r.Header.Get("X-Amz-Security-Token") != "SESSION" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var in map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		response, ok := responses[r.Header.Get("X-Amz-Target")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ResourceNotFoundException"}`))
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	client := &awsJSONClient{
+		region:   "us-east-1",
+		endpoint: server.URL,
+		credentials: func() awsCredentials {
+			return awsCredentials{accessKeyID: "AKID", secretAccessKey: "SECRET", sessionToken: "SESSION"}
+		},
+		client: server.Client(),
+		now:    time.Now,
+	}
+	return server, client
+}
+
+func TestAWSTokenProviders(t *testing.T) {
+	server, client := awsStandIn(map[string]string{
+		"AmazonSSM.GetParameter":        `{"Parameter":{"Name":"/signalfx/token","Type":"SecureString","Value":"SSM_TOKEN"}}`,
+		"secretsmanager.GetSecretValue": `{"Name":"signalfx","SecretString":"{\"token\":\"SECRET_TOKEN\"}"}`,
+	})
+	defer server.Close()
+	var tests = []struct {
+		provider TokenProvider
+		want     string
+	}{
+		{&ssmTokenProvider{api: client, name: "/signalfx/token"}, "SSM_TOKEN"},
+		{&secretsManagerTokenProvider{api: client, secretID: "signalfx", key: "token"}, "SECRET_TOKEN"},
+		{&secretsManagerTokenProvider{api: client, secretID: "signalfx"}, `{"token":"SECRET_TOKEN"}`},
+	}
+	for _, test := range tests {
+		if got, err := test.provider.Token(context.TODO()); err != nil || got != test.want {
+			t.Errorf("want token %s got %s %+v", test.want, got, err)
+		}
+	}
+	if _, err := (&secretsManagerTokenProvider{api: client, secretID: "signalfx", key: "missing"}).Token(context.TODO()); err == nil {
+		t.Errorf("want error for missing secret key")
+	}
+
+	failing, failingClient := awsStandIn(nil)
+	defer failing.Close()
+	if _, err := (&ssmTokenProvider{api: failingClient, name: "/signalfx/token"}).Token(context.TODO()); err == nil {
+		t.Errorf("want error for failed GetParameter call")
+	}
+}
+
+func TestFileTokenProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("FILE_TOKEN\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := (&fileTokenProvider{path: path}).Token(context.TODO()); err != nil || token != "FILE_TOKEN" {
+		t.Errorf("want token FILE_TOKEN got %s %+v", token, err)
+	}
+	if _, err := (&fileTokenProvider{path: filepath.Join(dir, "missing")}).Token(context.TODO()); err == nil {
+		t.Errorf("want error for missing token file")
+	}
+}
+
+func TestCachingTokenProvider(t *testing.T) {
+	calls := 0
+	var fail bool
+	provider := newCachingTokenProvider(TokenProviderFunc(func(context.Context) (string, error) {
+		calls++
+		if fail {
+			return "", errors.New("unavailable")
+		}
+		return "TOKEN" + string(rune('0'+calls)), nil
+	}), time.Minute)
+	now := time.Now()
+	provider.now = func() time.Time { return now }
+	var tests = []struct {
+		elapsed   time.Duration
+		fail      bool
+		want      string
+		wantCalls int
+	}{
+		{0, false, "TOKEN1", 1},
+		{30 * time.Second, false, "TOKEN1", 1},
+		{2 * time.Minute, false, "TOKEN2", 2},
+		{4 * time.Minute, true, "TOKEN2", 3},
+		{4*time.Minute + time.Second, true, "TOKEN2", 3},
+	}
+	start := now
+	for i, test := range tests {
+		now, fail = start.Add(test.elapsed), test.fail
+		if got, err := provider.Token(context.TODO()); err != nil || got != test.want || calls != test.wantCalls {
+			t.Errorf("test %d. want %s after %d calls got %s after %d calls %+v", i, test.want, test.wantCalls, got, calls, err)
+		}
+	}
+	fail = true
+	if _, err := newCachingTokenProvider(provider.provider, time.Minute).Token(context.TODO()); err == nil {
+		t.Errorf("want error without cached token")
+	}
+}
+
+func TestCachingTokenProviderInFlight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	provider := newCachingTokenProvider(TokenProviderFunc(func(context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "TOKEN", nil
+	}), time.Minute)
+	const callers = 10
+	tokens := make(chan string, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			token, _ := provider.Token(context.TODO())
+			tokens <- token
+		}()
+	}
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if _, err := provider.Token(c); err != context.Canceled {
+		t.Errorf("want canceled caller not blocked by the fetch in flight got %+v", err)
+	}
+	close(release)
+	for i := 0; i < callers; i++ {
+		if token := <-tokens; token != "TOKEN" {
+			t.Errorf("want TOKEN got %s", token)
+		}
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 1 {
+		t.Errorf("want 1 fetch for concurrent callers got %d", calls)
+	}
+}
+
+func TestParseTokenProvider(t *testing.T) {
+	var tests = []struct {
+		value   string
+		wantErr bool
+	}{
+		{"env", false},
+		{"file:/var/task/token", false},
+		{"ssm:/signalfx/token", false},
+		{"secretsmanager:signalfx#token", false},
+		{"ssm:", true},
+		{"vault:secret", true},
+	}
+	for _, test := range tests {
+		provider, err := parseTokenProvider(test.value, http.DefaultClient)
+		if (err != nil) != test.wantErr {
+			t.Errorf("value %s. want error %t got %+v", test.value, test.wantErr, err)
+		}
+		if p, ok := provider.(*secretsManagerTokenProvider); ok && (p.secretID != "signalfx" || p.key != "token") {
+			t.Errorf("want secret id signalfx and key token got %s %s", p.secretID, p.key)
+		}
+	}
+}
+
+func TestSetTokenProvider(t *testing.T) {
+	savedClient, savedToken := handlerFuncWrapperClient.Client, handlerFuncWrapperClient.AuthToken
+	defer func() {
+		handlerFuncWrapperClient.Client, handlerFuncWrapperClient.AuthToken = savedClient, savedToken
+	}()
+	var tokens []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get(sfxclient.TokenHeaderName))
+		w.Write([]byte(`"OK"`))
+	}))
+	defer receiver.Close()
+	SetTokenProvider(TokenProviderFunc(func(context.Context) (string, error) {
+		return "PROVIDED", nil
+	}))
+	if handlerFuncWrapperClient.Client == savedClient {
+		t.Errorf("want client of the sink replaced")
+	}
+	for _, token := range []string{"", "ROUTED"} {
+		req, _ := http.NewRequest("POST", receiver.URL, nil)
+		req.Header.Set(sfxclient.TokenHeaderName, token)
+		resp, err := handlerFuncWrapperClient.Client.Do(req)
+		if err != nil {
+			t.Fatalf("want no error got %+v", err)
+		}
+		resp.Body.Close()
+	}
+	if len(tokens) != 2 || tokens[0] != "PROVIDED" || tokens[1] != "ROUTED" {
+		t.Errorf("want tokens PROVIDED and ROUTED got %v", tokens)
+	}
+}
@@ -0,0 +1,199 @@
+package sfxlambda
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/signalfx/golib/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	serverKind = "SERVER"
+)
+
+type spanContextKey struct{}
+
+// Span is a handle to a span of the current invocation trace. Spans are sent to SignalFx together with the invocation
+// span when the wrapped handler returns. A Span that is not part of an invocation trace, e.g. because tracing is
+// disabled, is a no-op.
+type Span struct {
+	mu        sync.Mutex
+	span      *trace.Span
+	collector *spanCollector
+	finished  bool
+}
+
+// spanCollector collects the finished spans of an invocation trace. sampled and reason hold the head sampling
+// decision of the trace.
+type spanCollector struct {
+	mu      sync.Mutex
+	spans   []*trace.Span
+	sampled bool
+	reason  string
+}
+
+func (c *spanCollector) add(span *trace.Span) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spans = append(c.spans, span)
+}
+
+func (c *spanCollector) flush() []*trace.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	spans := c.spans
+	c.spans = nil
+	return spans
+}
+
+// StartSpan starts a span named name as a child of the span in ctx, normally the invocation span created by the
+// wrapper. The returned context carries the new span so that further spans started from it become its children. The
+// span must be finished with Finish to be sent.
+func StartSpan(ctx context.Context, name string) (*Span, context.Context) {
+	parent := SpanFromContext(ctx)
+	if parent == nil || parent.collector == nil {
+		return &Span{}, ctx
+	}
+	timestamp := time.Now().UnixNano() / int64(time.Microsecond)
+	parentID := parent.span.ID
+	span := &Span{
+		span: &trace.Span{
+			TraceID:       parent.span.TraceID,
+			ParentID:      &parentID,
+			ID:            newID(8),
+			Name:          &name,
+			Timestamp:     &timestamp,
+			LocalEndpoint: parent.span.LocalEndpoint,
+			Tags:          map[string]string{},
+		},
+		collector: parent.collector,
+	}
+	return span, contextWithSpan(ctx, span)
+}
+
+// SpanFromContext returns the span carried by ctx or nil if there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+func contextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SetTag sets the tag key of the span to value.
+func (s *Span) SetTag(key, value string) {
+	if s.span == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Tags[key] = value
+}
+
+// AddAnnotation adds an annotation with the current time to the span.
+func (s *Span) AddAnnotation(value string) {
+	if s.span == nil {
+		return
+	}
+	timestamp := time.Now().UnixNano() / int64(time.Microsecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Annotations = append(s.span.Annotations, &trace.Annotation{Timestamp: &timestamp, Value: &value})
+}
+
+// Finish sets the span duration and queues the span to be sent with the invocation span. Calls after the first one
+// have no effect.
+func (s *Span) Finish() {
+	s.finish(time.Now(), nil)
+}
+
+func (s *Span) finish(end time.Time, err error) {
+	if s.span == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return
+	}
+	s.finished = true
+	duration := end.UnixNano()/int64(time.Microsecond) - *s.span.Timestamp
+	s.span.Duration = &duration
+	if err != nil {
+		s.span.Tags["error"] = "true"
+		s.span.Tags["error.message"] = err.Error()
+	}
+	s.collector.add(s.span)
+}
+
+// newID returns a random lowercase hexadecimal identifier of n bytes.
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Errorf("error generating random id. %+v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// invocationSpan creates the span covering one invocation of the wrapped handler. The span trace ID is derived from the
+// X-Ray root trace ID when X-Ray trace ID conversion is enabled, otherwise a random 64-bit trace ID is used. The head
+// sampling decision of the trace is made from ctx and the event ev.
+func (hw *handlerWrapper) invocationSpan(ctx context.Context, ev *eventPayload, start time.Time, coldStart bool) *Span {
+	name := lambdacontext.FunctionName
+	kind := serverKind
+	timestamp := start.UnixNano() / int64(time.Microsecond)
+	span := &trace.Span{
+		TraceID:       newID(8),
+		ID:            newID(8),
+		Name:          &name,
+		Kind:          &kind,
+		Timestamp:     &timestamp,
+		LocalEndpoint: &trace.Endpoint{ServiceName: &name},
+		Tags:          map[string]string{},
+	}
+	// Default dimensions are best effort on spans. Errors are reported when sending datapoints.
+	dims, _ := defaultDimensions(ctx)
+	for k, v := range dims {
+		span.Tags[k] = v
+	}
+	if lambdaContext, ok := lambdacontext.FromContext(ctx); ok {
+		span.Tags["aws_request_id"] = lambdaContext.AwsRequestID
+	}
+	if coldStart {
+		span.Tags["cold_start"] = "true"
+	}
+	if xray, err := XRayTraceHeaderFromContext(ctx); err == nil {
+		span.Tags[xrayTraceIDTag] = xray.Root
+		if traceIDFromXRay {
+			if traceID, err := ZipkinTraceID(xray.Root); err == nil {
+				span.TraceID = traceID
+			} else {
+				log.Error(err)
+			}
+		}
+	}
+	collector := &spanCollector{}
+	collector.sampled, collector.reason = sampling.headDecision(ctx, ev)
+	return &Span{span: span, collector: collector}
+}
+
+// finishInvocationSpan finishes the invocation span and sends the spans of the invocation trace if it is sampled. The
+// sampling decision is recorded as the sampling.reason tag of the invocation span.
+func (hw *handlerWrapper) finishInvocationSpan(ctx context.Context, span *Span, start, end time.Time, err error) {
+	sampled, reason := sampling.tailDecision(span.collector.sampled, span.collector.reason, end.Sub(start), err)
+	span.SetTag(samplingReasonTag, reason)
+	span.finish(end, err)
+	spans := span.collector.flush()
+	if !sampled {
+		return
+	}
+	if err := sendSpans(ctx, spans); err != nil {
+		log.Error(err)
+	}
+}
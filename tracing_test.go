@@ -0,0 +1,71 @@
+package sfxlambda
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/trace"
+)
+
+func TestChildSpans(t *testing.T) {
+	savedSendDatapoints, savedSendSpans, savedTracingEnabled := sendDatapoints, sendSpans, tracingEnabled
+	defer func() {
+		sendDatapoints, sendSpans, tracingEnabled = savedSendDatapoints, savedSendSpans, savedTracingEnabled
+	}()
+	sendDatapoints = func(context.Context, []*datapoint.Datapoint) error {
+		return nil
+	}
+	var got []*trace.Span
+	sendSpans = func(_ context.Context, spans []*trace.Span) error {
+		got = spans
+		return nil
+	}
+	tracingEnabled = true
+	handlerFunc := func(ctx context.Context) error {
+		span, ctx := StartSpan(ctx, "db_query")
+		span.SetTag("db_name", "mysql1")
+		span.AddAnnotation("connected")
+		child, _ := StartSpan(ctx, "row_scan")
+		child.Finish()
+		span.Finish()
+		return nil
+	}
+	input, _ := json.Marshal("")
+	if _, err := NewHandlerWrapper(lambda.NewHandler(handlerFunc)).Invoke(ctx, input); err != nil {
+		t.Fatalf("valid lambda handler function invocation error. got %+v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("want 3 spans got %d", len(got))
+	}
+	rowScan, dbQuery, invocation := got[0], got[1], got[2]
+	if invocation.ParentID != nil {
+		t.Errorf("want invocation span without parent got %s", *invocation.ParentID)
+	}
+	if *dbQuery.ParentID != invocation.ID || *rowScan.ParentID != dbQuery.ID {
+		t.Errorf("invalid span hierarchy. got %s <- %s <- %s", invocation.ID, *dbQuery.ParentID, *rowScan.ParentID)
+	}
+	for _, span := range got {
+		if span.TraceID != invocation.TraceID {
+			t.Errorf("want trace id %s got %s", invocation.TraceID, span.TraceID)
+		}
+		if span.Duration == nil {
+			t.Errorf("want duration set on span %s", *span.Name)
+		}
+	}
+	if dbQuery.Tags["db_name"] != "mysql1" || len(dbQuery.Annotations) != 1 {
+		t.Errorf("want db_name tag and one annotation got %+v %+v", dbQuery.Tags, dbQuery.Annotations)
+	}
+}
+
+func TestStartSpanWithoutInvocationSpan(t *testing.T) {
+	span, c := StartSpan(context.TODO(), "noop")
+	span.SetTag("key", "value")
+	span.AddAnnotation("value")
+	span.Finish()
+	if SpanFromContext(c) != nil {
+		t.Errorf("want no span in context")
+	}
+}
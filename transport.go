@@ -0,0 +1,102 @@
+package sfxlambda
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/signalfx/golib/datapoint"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	clientKind = "CLIENT"
+
+	b3TraceIDHeader      = "X-B3-TraceId"
+	b3SpanIDHeader       = "X-B3-SpanId"
+	b3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	b3SampledHeader      = "X-B3-Sampled"
+)
+
+// transport is an http.RoundTripper that instruments requests sent by the wrapped http.RoundTripper.
+type transport struct {
+	base http.RoundTripper
+}
+
+// WrapTransport returns an http.RoundTripper that sends the requests through base and records the http.client.requests
+// counter and the http.client.duration gauge per host. When tracing is enabled a client span is created for every
+// request made with the invocation context and B3 headers are injected into the request. A nil base is
+// http.DefaultTransport.
+func WrapTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+// RoundTrip is transport's http.RoundTripper implementation.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	span, _ := StartSpan(ctx, req.Method+" "+req.URL.Host)
+	if span.span != nil {
+		span.setKind(clientKind)
+		span.SetTag("http.method", req.Method)
+		span.SetTag("http.url", req.URL.String())
+		span.SetTag("peer.hostname", req.URL.Hostname())
+		// A RoundTripper must not modify the request.
+		req = cloneRequestHeader(req)
+		span.injectB3(req.Header)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	end := time.Now()
+	statusCodeClass := "error"
+	if err == nil {
+		statusCodeClass = strconv.Itoa(resp.StatusCode/100) + "xx"
+		span.SetTag("http.status_code", strconv.Itoa(resp.StatusCode))
+	}
+	span.finish(end, err)
+	dims := map[string]string{"host": req.URL.Host}
+	dps := []*datapoint.Datapoint{
+		{Metric: "http.client.requests", Value: datapoint.NewIntValue(1), MetricType: datapoint.Counter,
+			Dimensions: datapoint.AddMaps(dims, map[string]string{"status_code_class": statusCodeClass})},
+		{Metric: "http.client.duration", Value: datapoint.NewIntValue(Milliseconds(end.Sub(start))), MetricType: datapoint.Gauge,
+			Dimensions: dims},
+	}
+	if err2 := RecordDatapoints(ctx, dps); err2 != nil {
+		log.Error(err2)
+	}
+	return resp, err
+}
+
+func (s *Span) setKind(kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Kind = &kind
+}
+
+// injectB3 sets the B3 propagation headers of the span in header.
+func (s *Span) injectB3(header http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	header.Set(b3TraceIDHeader, s.span.TraceID)
+	header.Set(b3SpanIDHeader, s.span.ID)
+	if s.span.ParentID != nil {
+		header.Set(b3ParentSpanIDHeader, *s.span.ParentID)
+	}
+	if s.collector.sampled {
+		header.Set(b3SampledHeader, "1")
+	} else {
+		header.Set(b3SampledHeader, "0")
+	}
+}
+
+// cloneRequestHeader returns a shallow copy of req with a deep copy of its header, for RoundTrippers adding headers.
+func cloneRequestHeader(req *http.Request) *http.Request {
+	clone := *req
+	clone.Header = make(http.Header, len(req.Header))
+	for k, vs := range req.Header {
+		clone.Header[k] = append([]string(nil), vs...)
+	}
+	return &clone
+}
@@ -0,0 +1,74 @@
+package sfxlambda
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/trace"
+)
+
+func TestWrapTransport(t *testing.T) {
+	savedSendDatapoints, savedSendSpans, savedTracingEnabled := sendDatapoints, sendSpans, tracingEnabled
+	defer func() {
+		sendDatapoints, sendSpans, tracingEnabled = savedSendDatapoints, savedSendSpans, savedTracingEnabled
+	}()
+	var dps []*datapoint.Datapoint
+	sendDatapoints = func(_ context.Context, got []*datapoint.Datapoint) error {
+		dps = got
+		return nil
+	}
+	var spans []*trace.Span
+	sendSpans = func(_ context.Context, got []*trace.Span) error {
+		spans = got
+		return nil
+	}
+	tracingEnabled = true
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: WrapTransport(nil)}
+	handlerFunc := func(ctx context.Context) error {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if _, err := NewHandlerWrapper(lambda.NewHandler(handlerFunc)).Invoke(ctx, []byte(`""`)); err != nil {
+		t.Fatalf("valid lambda handler function invocation error. got %+v", err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans got %d", len(spans))
+	}
+	clientSpan := spans[0]
+	if *clientSpan.Kind != clientKind || clientSpan.Tags["http.status_code"] != "404" {
+		t.Errorf("want client span with status code 404 got %+v", clientSpan)
+	}
+	if header.Get(b3TraceIDHeader) != clientSpan.TraceID || header.Get(b3SpanIDHeader) != clientSpan.ID ||
+		header.Get(b3ParentSpanIDHeader) != spans[1].ID {
+		t.Errorf("invalid b3 headers. got %+v", header)
+	}
+	var requests *datapoint.Datapoint
+	for _, dp := range dps {
+		if dp.Metric == "http.client.requests" {
+			requests = dp
+		}
+	}
+	if requests == nil {
+		t.Fatalf("want http.client.requests datapoint got %+v", dps)
+	}
+	if requests.Dimensions["status_code_class"] != "4xx" || requests.Dimensions["host"] == "" || requests.Dimensions["lambda_arn"] == "" {
+		t.Errorf("invalid http.client.requests dimensions. got %+v", requests.Dimensions)
+	}
+}
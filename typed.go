@@ -0,0 +1,78 @@
+package sfxlambda
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/signalfx/golib/datapoint"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// MetricExtractor derives datapoints from the decoded input of a handler function wrapped with NewTypedHandler or
+// WrapFunc. input holds a value of the input type of the handler function. The datapoints are recorded with
+// RecordDatapoints, i.e. sent with the invocation metrics when the handler is wrapped by the Metrics middleware.
+type MetricExtractor func(ctx context.Context, input interface{}) []*datapoint.Datapoint
+
+// typedHandler is a lambda.Handler implementation for a handler function with a checked signature
+// func(context.Context, In) (Out, error).
+type typedHandler struct {
+	handlerFunc reflect.Value
+	inputType   reflect.Type
+	extractors  []MetricExtractor
+}
+
+// NewTypedHandler creates a lambda.Handler for handlerFunc, a function of signature func(context.Context, In) (Out, error)
+// where In and Out are types that encoding/json decodes and encodes. Without generics, which need Go 1.18, the signature
+// cannot be checked at compile time. Unlike lambda.NewHandler, which only fails at invocation time, NewTypedHandler
+// panics when called with a handler function of another signature, i.e. on cold start. The payload is decoded into In
+// and passed to the extractors and then handlerFunc. The handler can be combined with middlewares using Chain.
+func NewTypedHandler(handlerFunc interface{}, extractors ...MetricExtractor) lambda.Handler {
+	h, err := newTypedHandler(handlerFunc, extractors)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// WrapFunc creates a ContextHandlerWrapper for the handler created by NewTypedHandler, i.e. it is a shorthand for
+// NewHandlerWrapper(NewTypedHandler(handlerFunc, extractors...)).
+func WrapFunc(handlerFunc interface{}, extractors ...MetricExtractor) ContextHandlerWrapper {
+	return NewHandlerWrapper(NewTypedHandler(handlerFunc, extractors...))
+}
+
+func newTypedHandler(handlerFunc interface{}, extractors []MetricExtractor) (*typedHandler, error) {
+	t := reflect.TypeOf(handlerFunc)
+	if t == nil || t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("handler function of type %T is not a function", handlerFunc)
+	}
+	if t.NumIn() != 2 || t.In(0) != contextType || t.NumOut() != 2 || t.Out(1) != errorType {
+		return nil, fmt.Errorf("handler function of type %s is not of type func(context.Context, In) (Out, error)", t)
+	}
+	return &typedHandler{handlerFunc: reflect.ValueOf(handlerFunc), inputType: t.In(1), extractors: extractors}, nil
+}
+
+// Invoke is typedHandler's lambda.Handler implementation.
+func (h *typedHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	input := reflect.New(h.inputType)
+	if err := json.Unmarshal(payload, input.Interface()); err != nil {
+		return nil, err
+	}
+	for _, extract := range h.extractors {
+		if err := RecordDatapoints(ctx, extract(ctx, input.Elem().Interface())); err != nil {
+			log.Error(err)
+		}
+	}
+	out := h.handlerFunc.Call([]reflect.Value{reflect.ValueOf(ctx), input.Elem()})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return json.Marshal(out[0].Interface())
+}
@@ -0,0 +1,127 @@
+package sfxlambda
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/signalfx/golib/datapoint"
+)
+
+type order struct {
+	ID    string `json:"id"`
+	Items int    `json:"items"`
+}
+
+type receipt struct {
+	OrderID string `json:"orderId"`
+}
+
+func TestWrapFunc(t *testing.T) {
+	savedSendDatapoints := sendDatapoints
+	defer func() {
+		sendDatapoints = savedSendDatapoints
+	}()
+	var got []*datapoint.Datapoint
+	sendDatapoints = func(_ context.Context, dps []*datapoint.Datapoint) error {
+		got = dps
+		return nil
+	}
+	itemsExtractor := func(_ context.Context, input interface{}) []*datapoint.Datapoint {
+		o := input.(order)
+		return []*datapoint.Datapoint{{Metric: "order.items", Value: datapoint.NewIntValue(int64(o.Items)), MetricType: datapoint.Counter}}
+	}
+	handler := WrapFunc(func(_ context.Context, o order) (receipt, error) {
+		if o.ID == "" {
+			return receipt{}, errors.New("missing order id")
+		}
+		return receipt{OrderID: o.ID}, nil
+	}, itemsExtractor)
+
+	response, err := handler.Invoke(ctx, []byte(`{"id":"o-1","items":3}`))
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if string(response) != `{"orderId":"o-1"}` {
+		t.Errorf("want response {\"orderId\":\"o-1\"} got %s", response)
+	}
+	var items *datapoint.Datapoint
+	for _, dp := range got {
+		if dp.Metric == "order.items" {
+			items = dp
+		}
+	}
+	if items == nil || items.Value.(datapoint.IntValue).Int() != 3 || items.Dimensions["lambda_arn"] == "" {
+		t.Errorf("want order.items datapoint with value 3 and default dimensions got %+v", items)
+	}
+	if _, err := handler.Invoke(ctx, []byte(`{"items":1}`)); err == nil {
+		t.Errorf("want handler function error")
+	}
+	if _, err := handler.Invoke(ctx, []byte(`[1]`)); err == nil {
+		t.Errorf("want decoding error")
+	}
+}
+
+func TestWrapFuncSignature(t *testing.T) {
+	var tests = []interface{}{
+		nil,
+		"handler",
+		func(o order) (receipt, error) { return receipt{}, nil },
+		func(_ context.Context, o order) receipt { return receipt{} },
+		func(_ context.Context, o order) (receipt, string) { return receipt{}, "" },
+	}
+	for i, handlerFunc := range tests {
+		if _, err := newTypedHandler(handlerFunc, nil); err == nil {
+			t.Errorf("test %d. want signature error for %T", i, handlerFunc)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("want WrapFunc panic")
+		}
+	}()
+	WrapFunc(func(o order) error { return nil })
+}
+
+type typedEvent struct {
+	Records []struct {
+		MessageID string `json:"messageId"`
+		Body      string `json:"body"`
+	}
+}
+
+func TestNewTypedHandlerChain(t *testing.T) {
+	savedSendDatapoints := sendDatapoints
+	defer func() {
+		sendDatapoints = savedSendDatapoints
+	}()
+	var got []*datapoint.Datapoint
+	sendDatapoints = func(_ context.Context, dps []*datapoint.Datapoint) error {
+		got = dps
+		return nil
+	}
+	recordsExtractor := func(_ context.Context, input interface{}) []*datapoint.Datapoint {
+		ev := input.(typedEvent)
+		return []*datapoint.Datapoint{{Metric: "records", Value: datapoint.NewIntValue(int64(len(ev.Records))), MetricType: datapoint.Counter}}
+	}
+	handler := Chain(NewTypedHandler(func(_ context.Context, ev typedEvent) (string, error) {
+		return ev.Records[0].MessageID, nil
+	}, recordsExtractor), Metrics(), Recover())
+
+	response, err := handler.Invoke(ctx, []byte(sqsEvent))
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if string(response) != `"059f36b4-87a3-44ab-83d2-661975830a7d"` {
+		t.Errorf("want message id response got %s", response)
+	}
+	var records *datapoint.Datapoint
+	for _, dp := range got {
+		if dp.Metric == "records" {
+			records = dp
+		}
+	}
+	if records == nil || records.Value.(datapoint.IntValue).Int() != 2 || records.Dimensions["event_source"] != sqsSource {
+		t.Errorf("want records datapoint with value 2 and the event source dimension got %+v", records)
+	}
+}
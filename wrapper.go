@@ -5,17 +5,20 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/event"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	name    = "signalfx_lambda_go"
-	version = "0.0.1"
+	version = "0.1.0"
 )
 
 // HandlerWrapper extends interface lambda.Handler to support sending metric datapoints.
@@ -24,50 +27,140 @@ type HandlerWrapper interface {
 	SendDatapoints(dps []*datapoint.Datapoint) error
 }
 
-// handlerWrapper is a HandlerWrapper and lambda.Handler implementation.
+// ContextHandlerWrapper extends interface HandlerWrapper to support sending events and sending with the dimensions of
+// the invocation in ctx. The methods are not part of HandlerWrapper so that existing HandlerWrapper implementations keep
+// compiling.
+type ContextHandlerWrapper interface {
+	HandlerWrapper
+	SendDatapointsContext(ctx context.Context, dps []*datapoint.Datapoint) error
+	SendEvents(events []*event.Event) error
+	SendEventsContext(ctx context.Context, events []*event.Event) error
+}
+
+// handlerWrapper is a ContextHandlerWrapper and lambda.Handler implementation.
 // handlerWrapper delegates lambda handler function invocation to the embedded lambda.Handler.
+// handlerWrapper is safe for concurrent invocations. notColdStart is accessed atomically and ctx, the context of the
+// latest invocation, is guarded by mu.
 type handlerWrapper struct {
 	lambda.Handler
-	notColdStart bool
+	notColdStart int32
+	mu           sync.Mutex
 	ctx          context.Context
 }
 
-// NewHandlerWrapper is a HandlerWrapper creating factory function.
-func NewHandlerWrapper(handler lambda.Handler) HandlerWrapper {
+// NewHandlerWrapper is a ContextHandlerWrapper creating factory function.
+func NewHandlerWrapper(handler lambda.Handler) ContextHandlerWrapper {
 	return &handlerWrapper{Handler: handler}
 }
 
 // Invoke is handlerWrapper's lambda.Handler implementation that delegates to the Invoke method of the embedded lambda.Handler.
 // Invoke creates and sends metrics.
 func (hw *handlerWrapper) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
-	hw.ctx = ctx
+	hw.setContext(ctx)
 	dps := []*datapoint.Datapoint{hw.invocationsDatapoint()}
-	if !hw.notColdStart {
+	coldStart := atomic.CompareAndSwapInt32(&hw.notColdStart, 0, 1)
+	if coldStart {
 		dps = append(dps, hw.coldStartsDatapoint())
-		hw.notColdStart = true
 	}
+	collector := &datapointCollector{}
+	ctx = context.WithValue(ctx, datapointsContextKey{}, collector)
+	ev := parseEventPayload(payload)
+	eventDims := eventSourceDimensions(ev, eventSourceDetailDimensions)
+	dps = append(dps, eventDatapoints(ev, time.Now())...)
 	start := time.Now()
+	var span *Span
+	if tracingEnabled {
+		span = hw.invocationSpan(ctx, ev, start, coldStart)
+		for k, v := range eventDims {
+			span.SetTag(k, v)
+		}
+		ctx = contextWithSpan(ctx, span)
+	}
+	unsetLogFields := setInvocationLogFields(ctx)
+	defer unsetLogFields()
 	responseBytes, err := hw.Handler.Invoke(ctx, payload)
-	dps = append(dps, hw.durationDatapoint(time.Since(start)))
+	end := time.Now()
+	dps = append(dps, hw.durationDatapoint(end.Sub(start)))
+	dps = append(dps, httpDatapoints(ev, responseBytes, err, end.Sub(start))...)
+	dps = append(dps, batchDatapoints(ev, responseBytes, err)...)
 	if err != nil {
 		dps = append(dps, hw.errorsDatapoint())
 	}
+	dps = append(dps, collector.flush()...)
+	for _, dp := range dps {
+		dp.Dimensions = datapoint.AddMaps(eventDims, dp.Dimensions)
+	}
 	if err2 := hw.sendDatapoints(ctx, dps); err2 != nil {
 		log.Error(err2)
 	}
+	if span != nil {
+		hw.finishInvocationSpan(ctx, span, start, end, err)
+	}
 	return responseBytes, err
 }
 
 type dimensions map[string]string
 
+type datapointsContextKey struct{}
+
+// datapointCollector collects datapoints recorded during an invocation, e.g. by instrumented clients, so that they are
+// sent together with the wrapper datapoints when the invocation ends.
+type datapointCollector struct {
+	mu  sync.Mutex
+	dps []*datapoint.Datapoint
+}
+
+func (c *datapointCollector) add(dps ...*datapoint.Datapoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dps = append(c.dps, dps...)
+}
+
+func (c *datapointCollector) flush() []*datapoint.Datapoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dps := c.dps
+	c.dps = nil
+	return dps
+}
+
+// RecordDatapoints adds dps to the datapoints of the invocation in ctx, which are sent when the invocation ends.
+// Outside of an invocation dps are sent immediately.
+func RecordDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
+	if c, ok := ctx.Value(datapointsContextKey{}).(*datapointCollector); ok {
+		c.add(dps...)
+		return nil
+	}
+	return (&handlerWrapper{}).sendDatapoints(ctx, dps)
+}
+
 // Start takes HandlerWrapper, a lambda.Handler implementation and passes it function lambda.StartHandler
 func Start(handler HandlerWrapper) {
 	lambda.StartHandler(handler)
 }
 
-// SendDatapoints sends custom metric datapoints to SignalFx.
+// SendDatapoints sends custom metric datapoints to SignalFx with the dimensions of the latest invocation. Use
+// SendDatapointsContext with concurrent invocations.
 func (hw *handlerWrapper) SendDatapoints(dps []*datapoint.Datapoint) error {
-	return hw.sendDatapoints(hw.ctx, dps)
+	return hw.sendDatapoints(hw.invocationContext(), dps)
+}
+
+// SendDatapointsContext sends custom metric datapoints to SignalFx with the dimensions of the invocation in ctx, the
+// context passed to the handler function.
+func (hw *handlerWrapper) SendDatapointsContext(ctx context.Context, dps []*datapoint.Datapoint) error {
+	return hw.sendDatapoints(ctx, dps)
+}
+
+func (hw *handlerWrapper) setContext(ctx context.Context) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	hw.ctx = ctx
+}
+
+func (hw *handlerWrapper) invocationContext() context.Context {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	return hw.ctx
 }
 
 func (hw *handlerWrapper) sendDatapoints(ctx context.Context, dps []*datapoint.Datapoint) error {
@@ -90,7 +183,55 @@ func (hw *handlerWrapper) sendDatapoints(ctx context.Context, dps []*datapoint.D
 	if len(errs) == 0 {
 		return nil
 	}
-	return fmt.Errorf(strings.Join(errs, "\n"))
+	return fmt.Errorf("%s", strings.Join(errs, "\n"))
+}
+
+// SendEvents sends custom events to SignalFx with the dimensions of the latest invocation. The X-Ray trace ID of the
+// latest invocation is added as the aws_xray_trace_id event property if enabled. Use SendEventsContext with concurrent
+// invocations.
+func (hw *handlerWrapper) SendEvents(events []*event.Event) error {
+	return hw.sendEvents(hw.invocationContext(), events)
+}
+
+// SendEventsContext sends custom events to SignalFx with the dimensions and X-Ray trace ID of the invocation in ctx,
+// the context passed to the handler function.
+func (hw *handlerWrapper) SendEventsContext(ctx context.Context, events []*event.Event) error {
+	return hw.sendEvents(ctx, events)
+}
+
+func (hw *handlerWrapper) sendEvents(ctx context.Context, events []*event.Event) error {
+	if ctx == nil {
+		return fmt.Errorf("invalid argument. context is nil")
+	}
+	var errs []string
+	dims, err := defaultDimensions(ctx)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	var xrayRoot string
+	if xrayEventProperty {
+		if xray, err := XRayTraceHeaderFromContext(ctx); err == nil {
+			xrayRoot = xray.Root
+		} else {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, e := range events {
+		e.Dimensions = datapoint.AddMaps(dims, e.Dimensions)
+		if xrayRoot != "" {
+			if e.Properties == nil {
+				e.Properties = map[string]interface{}{}
+			}
+			e.Properties[xrayTraceIDTag] = xrayRoot
+		}
+	}
+	if err = sendEvents(ctx, events); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "\n"))
 }
 
 // defaultDimensions derives metric dimensions from AWS Lambda ARN. Formats and examples of AWS Lambda ARNs are in the
@@ -145,7 +286,7 @@ func defaultDimensions(ctx context.Context) (map[string]string, error) {
 	if len(errs) == 0 {
 		return dims, nil
 	}
-	return dims, fmt.Errorf(strings.Join(errs, "\n"))
+	return dims, fmt.Errorf("%s", strings.Join(errs, "\n"))
 }
 
 func (ds dimensions) addArnDerivedDimension(dimension string, arnSubstrings []string, arnSubstringIndex int) error {
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/event"
 	"github.com/signalfx/golib/sfxclient"
+	"github.com/signalfx/golib/trace"
 	log "github.com/sirupsen/logrus"
-	"net/url"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -14,35 +16,154 @@ import (
 
 var handlerFuncWrapperClient *sfxclient.HTTPSink
 
+// datapointSink is the sink of datapoints selected by the metrics transport, handlerFuncWrapperClient by default.
+var datapointSink sfxclient.Sink
+
+// spanSink is the sink of spans selected by the traces transport, handlerFuncWrapperClient by default.
+var spanSink trace.Sink
+
+var (
+	tracingEnabled              bool
+	traceIDFromXRay             bool
+	xrayEventProperty           bool
+	eventSourceDetailDimensions bool
+	forwarderBatchSize          = defaultForwarderBatchSize
+)
+
 const (
-	sfxAuthToken          = "SIGNALFX_AUTH_TOKEN"
-	sfxIngestEndpoint     = "SIGNALFX_INGEST_ENDPOINT"
-	sfxSendTimeoutSeconds = "SIGNALFX_SEND_TIMEOUT_SECONDS"
+	sfxAuthToken                   = "SIGNALFX_AUTH_TOKEN"
+	sfxAuthTokenSource             = "SIGNALFX_AUTH_TOKEN_SOURCE"
+	sfxAuthTokenRefreshSeconds     = "SIGNALFX_AUTH_TOKEN_REFRESH_SECONDS"
+	sfxIngestEndpoint              = "SIGNALFX_INGEST_ENDPOINT"
+	sfxRealm                       = "SIGNALFX_REALM"
+	sfxMetricsURL                  = "SIGNALFX_METRICS_URL"
+	sfxEventsURL                   = "SIGNALFX_EVENTS_URL"
+	sfxTracesURL                   = "SIGNALFX_TRACES_URL"
+	sfxSendTimeoutSeconds          = "SIGNALFX_SEND_TIMEOUT_SECONDS"
+	sfxTracingEnabled              = "SIGNALFX_TRACING_ENABLED"
+	sfxTraceIDFromXRay             = "SIGNALFX_TRACE_ID_FROM_XRAY"
+	sfxXRayEventProperty           = "SIGNALFX_XRAY_EVENT_PROPERTY"
+	sfxTraceSampleRate             = "SIGNALFX_TRACE_SAMPLE_RATE"
+	sfxTraceHonorUpstream          = "SIGNALFX_TRACE_HONOR_UPSTREAM_SAMPLING"
+	sfxTraceSampleErrors           = "SIGNALFX_TRACE_SAMPLE_ERRORS"
+	sfxTraceSlowMs                 = "SIGNALFX_TRACE_SAMPLE_SLOW_MS"
+	sfxTraceRateLimit              = "SIGNALFX_TRACE_RATE_LIMIT"
+	sfxEventSourceDetailDimensions = "SIGNALFX_EVENT_SOURCE_DETAIL_DIMENSIONS"
+	sfxMetricsTransport            = "SIGNALFX_METRICS_TRANSPORT"
+	sfxForwarderBatchSize          = "SIGNALFX_FORWARDER_BATCH_SIZE"
+	sfxTracesTransport             = "SIGNALFX_TRACES_TRANSPORT"
+	sfxPrometheusRemoteWriteURL    = "SIGNALFX_PROMETHEUS_REMOTE_WRITE_URL"
+	sfxStatsdAddress               = "SIGNALFX_STATSD_ADDRESS"
+	sfxStatsdMaxPacketSize         = "SIGNALFX_STATSD_MAX_PACKET_SIZE"
+	sfxDatapointFormat             = "SIGNALFX_DATAPOINT_FORMAT"
+	sfxDebugPayloads               = "SIGNALFX_DEBUG_PAYLOADS"
+	sfxRoutingConfig               = "SIGNALFX_ROUTING_CONFIG"
+	sfxStrictConfig                = "SIGNALFX_STRICT_CONFIG"
+
+	otelExporterOTLPEndpoint        = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otelExporterOTLPMetricsEndpoint = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+	otelExporterOTLPTracesEndpoint  = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+)
+
+// Paths of the ingest API relative to the ingest endpoint.
+const (
+	datapointPath = "v2/datapoint"
+	eventPath     = "v2/event"
+	tracePath     = "v1/trace"
+)
+
+// Transports of SIGNALFX_METRICS_TRANSPORT and SIGNALFX_TRACES_TRANSPORT. The log, prometheus and statsd transports are for metrics only.
+const (
+	httpTransport       = "http"
+	logTransport        = "log"
+	otlpTransport       = "otlp"
+	prometheusTransport = "prometheus"
+	statsdTransport     = "statsd"
 )
 
 func init() {
+	config, configErrs := configFromEnv(os.Getenv)
 	handlerFuncWrapperClient = sfxclient.NewHTTPSink()
-	if handlerFuncWrapperClient.AuthToken = os.Getenv(sfxAuthToken); handlerFuncWrapperClient.AuthToken == "" {
-		log.Errorf("no value for environment variable %s", sfxAuthToken)
+	handlerFuncWrapperClient.AuthToken = config.AuthToken
+	handlerFuncWrapperClient.DatapointEndpoint = config.DatapointEndpoint
+	handlerFuncWrapperClient.EventEndpoint = config.EventEndpoint
+	handlerFuncWrapperClient.TraceEndpoint = config.TraceEndpoint
+	if config.SendTimeout > 0 {
+		handlerFuncWrapperClient.Client.Timeout = config.SendTimeout
 	}
-	if os.Getenv(sfxIngestEndpoint) != "" {
-		if ingestURL, err := url.Parse(os.Getenv(sfxIngestEndpoint)); err == nil {
-			if ingestURL, err = ingestURL.Parse("v2/datapoint"); err == nil {
-				handlerFuncWrapperClient.DatapointEndpoint = ingestURL.String()
+	tracingEnabled = config.enabled(sfxTracingEnabled)
+	traceIDFromXRay = config.enabled(sfxTraceIDFromXRay)
+	xrayEventProperty = config.enabled(sfxXRayEventProperty)
+	eventSourceDetailDimensions = config.enabled(sfxEventSourceDetailDimensions)
+	// Invalid values, unknown transports and formats are reported by Config.Validate and use the defaults.
+	if config.ForwarderBatchSize > 0 {
+		forwarderBatchSize = config.ForwarderBatchSize
+	}
+	datapointSink = handlerFuncWrapperClient
+	switch config.MetricsTransport {
+	case "", httpTransport:
+		if config.DatapointFormat == jsonFormat {
+			datapointSink = &jsonDatapointSink{sink: handlerFuncWrapperClient, debug: config.enabled(sfxDebugPayloads)}
+		}
+		if value := os.Getenv(sfxRoutingConfig); value != "" {
+			if sink, err := routingSinkFromConfig(value, datapointSink); err == nil {
+				datapointSink = sink
 			} else {
-				log.Errorf("error parsing ingest url path v2/datapoint: %+v", err)
+				configErrs = append(configErrs, &ValidationError{Variable: sfxRoutingConfig, Value: value, Message: err.Error()})
 			}
+		}
+	case logTransport:
+		datapointSink = &logSink{out: os.Stdout}
+	case prometheusTransport:
+		if remoteWriteURL := strings.TrimSpace(os.Getenv(sfxPrometheusRemoteWriteURL)); remoteWriteURL != "" {
+			datapointSink = &prometheusRemoteWriteSink{url: remoteWriteURL, client: handlerFuncWrapperClient.Client}
+		} else {
+			configErrs = append(configErrs, &ValidationError{Variable: sfxPrometheusRemoteWriteURL, Message: "no value for the prometheus transport"})
+		}
+	case statsdTransport:
+		address := strings.TrimSpace(os.Getenv(sfxStatsdAddress))
+		if address == "" {
+			address = defaultStatsdAddress
+		}
+		if sink, err := newStatsdSink(address, config.StatsdMaxPacketSize); err == nil {
+			datapointSink = sink
 		} else {
-			log.Errorf("error parsing url value %s of environment variable %s. %+v", os.Getenv(sfxIngestEndpoint), sfxIngestEndpoint, err)
+			configErrs = append(configErrs, &ValidationError{Variable: sfxStatsdAddress, Value: address, Message: err.Error()})
 		}
+	case otlpTransport:
+		datapointSink = &otlpMetricsSink{endpoint: otlpEndpoint(otelExporterOTLPMetricsEndpoint, otlpMetricsPath), client: handlerFuncWrapperClient.Client}
 	}
-	if os.Getenv(sfxSendTimeoutSeconds) != "" {
-		if timeout, err := time.ParseDuration(strings.TrimSpace(os.Getenv(sfxSendTimeoutSeconds)) + "s"); err == nil {
-			handlerFuncWrapperClient.Client.Timeout = timeout
+	spanSink = handlerFuncWrapperClient
+	if config.TracesTransport == otlpTransport {
+		spanSink = &otlpTracesSink{endpoint: otlpEndpoint(otelExporterOTLPTracesEndpoint, otlpTracesPath), client: handlerFuncWrapperClient.Client}
+	}
+	if config.AuthTokenSource != "" {
+		// The token is set on a new client of the sink, after the other sinks are created with the previous client. The
+		// AWS API calls of the providers must not go through either.
+		if provider, err := parseTokenProvider(config.AuthTokenSource, &http.Client{Timeout: handlerFuncWrapperClient.Client.Timeout}); err == nil {
+			refresh := config.AuthTokenRefresh
+			if refresh < 0 {
+				refresh = defaultTokenRefresh
+			}
+			SetTokenProvider(newCachingTokenProvider(provider, refresh))
 		} else {
-			log.Errorf("error parsing timeout value %s of environment variable %s. %+v", os.Getenv(sfxSendTimeoutSeconds), sfxSendTimeoutSeconds, err)
+			configErrs = append(configErrs, &ValidationError{Variable: sfxAuthTokenSource, Value: config.AuthTokenSource, Message: err.Error()})
 		}
 	}
+	sampling = newSampler(config)
+	if err := checkConfig(config, configErrs); err != nil {
+		log.Fatalf("invalid configuration in strict mode %s=true. %+v", sfxStrictConfig, err)
+	}
+}
+
+// routingSinkFromConfig returns the routingSink of the routing config value routing the datapoints matching no rule to
+// defaultSink.
+func routingSinkFromConfig(value string, defaultSink sfxclient.Sink) (sfxclient.Sink, error) {
+	config, err := parseRoutingConfig(value)
+	if err != nil {
+		return nil, err
+	}
+	return newRoutingSink(config, defaultSink, handlerFuncWrapperClient.DatapointEndpoint, handlerFuncWrapperClient.UserAgent, handlerFuncWrapperClient.Client)
 }
 
 var sendDatapoints = func(ctx context.Context, dps []*datapoint.Datapoint) error {
@@ -52,8 +173,28 @@ var sendDatapoints = func(ctx context.Context, dps []*datapoint.Datapoint) error
 			dp.Timestamp = now
 		}
 	}
-	if err := handlerFuncWrapperClient.AddDatapoints(ctx, dps); err != nil {
+	if err := datapointSink.AddDatapoints(ctx, dps); err != nil {
 		return fmt.Errorf("error sending datapoint to SignalFx. %+v", err)
 	}
 	return nil
 }
+
+var sendEvents = func(ctx context.Context, events []*event.Event) error {
+	now := time.Now()
+	for _, e := range events {
+		if e.Timestamp.IsZero() {
+			e.Timestamp = now
+		}
+	}
+	if err := handlerFuncWrapperClient.AddEvents(ctx, events); err != nil {
+		return fmt.Errorf("error sending event to SignalFx. %+v", err)
+	}
+	return nil
+}
+
+var sendSpans = func(ctx context.Context, spans []*trace.Span) error {
+	if err := spanSink.AddSpans(ctx, spans); err != nil {
+		return fmt.Errorf("error sending span to SignalFx. %+v", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package sfxlambda
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	xrayTraceIDEnv        = "_X_AMZN_TRACE_ID"
+	xrayTraceIDContextKey = "x-amzn-trace-id"
+	xrayTraceIDTag        = "aws_xray_trace_id"
+)
+
+// XRayTraceHeader holds the fields of an AWS X-Ray tracing header such as
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+type XRayTraceHeader struct {
+	Root    string
+	Parent  string
+	Sampled string
+}
+
+// ParseXRayTraceHeader parses an AWS X-Ray tracing header. Unknown fields are ignored.
+func ParseXRayTraceHeader(header string) (*XRayTraceHeader, error) {
+	var h XRayTraceHeader
+	for _, field := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Root":
+			h.Root = kv[1]
+		case "Parent":
+			h.Parent = kv[1]
+		case "Sampled":
+			h.Sampled = kv[1]
+		}
+	}
+	if h.Root == "" {
+		return nil, fmt.Errorf("invalid x-ray trace header. no Root field in %s", header)
+	}
+	return &h, nil
+}
+
+// XRayTraceHeaderFromContext returns the X-Ray tracing header of the current invocation. The header is read from the
+// invocation context and falls back to the _X_AMZN_TRACE_ID environment variable.
+func XRayTraceHeaderFromContext(ctx context.Context) (*XRayTraceHeader, error) {
+	header, _ := ctx.Value(xrayTraceIDContextKey).(string)
+	if header == "" {
+		header = os.Getenv(xrayTraceIDEnv)
+	}
+	if header == "" {
+		return nil, fmt.Errorf("no x-ray trace header in context or environment variable %s", xrayTraceIDEnv)
+	}
+	return ParseXRayTraceHeader(header)
+}
+
+// ZipkinTraceID converts an X-Ray root trace ID (e.g. 1-5759e988-bd862e3fe1be46a994272793) into a 128-bit Zipkin
+// compatible trace ID by concatenating the 32-bit epoch and the 96-bit unique identifier.
+func ZipkinTraceID(xrayRoot string) (string, error) {
+	parts := strings.Split(xrayRoot, "-")
+	if len(parts) != 3 || parts[0] != "1" || len(parts[1]) != 8 || len(parts[2]) != 24 {
+		return "", fmt.Errorf("invalid x-ray root trace id %s", xrayRoot)
+	}
+	id := strings.ToLower(parts[1] + parts[2])
+	for _, r := range id {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", fmt.Errorf("invalid x-ray root trace id %s. non hexadecimal character %q", xrayRoot, r)
+		}
+	}
+	return id, nil
+}
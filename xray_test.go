@@ -0,0 +1,131 @@
+package sfxlambda
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/signalfx/golib/datapoint"
+	"github.com/signalfx/golib/event"
+	"github.com/signalfx/golib/trace"
+)
+
+func TestParseXRayTraceHeader(t *testing.T) {
+	var tests = []struct {
+		header  string
+		want    XRayTraceHeader
+		wantErr bool
+	}{
+		{"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+			XRayTraceHeader{Root: "1-5759e988-bd862e3fe1be46a994272793", Parent: "53995c3f42cd8ad8", Sampled: "1"},
+			false,
+		},
+		{"Root=1-5759e988-bd862e3fe1be46a994272793",
+			XRayTraceHeader{Root: "1-5759e988-bd862e3fe1be46a994272793"},
+			false,
+		},
+		{"Parent=53995c3f42cd8ad8;Sampled=0", XRayTraceHeader{}, true},
+		{"", XRayTraceHeader{}, true},
+	}
+	for _, test := range tests {
+		got, err := ParseXRayTraceHeader(test.header)
+		if (err != nil) != test.wantErr {
+			t.Errorf("header %s. want error %t got %+v", test.header, test.wantErr, err)
+			continue
+		}
+		if err == nil && *got != test.want {
+			t.Errorf("header %s. want %+v got %+v", test.header, test.want, *got)
+		}
+	}
+}
+
+func TestXRayTraceHeaderFromContext(t *testing.T) {
+	c := context.WithValue(context.TODO(), xrayTraceIDContextKey, "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+	got, err := XRayTraceHeaderFromContext(c)
+	if err != nil {
+		t.Fatalf("want no error got %+v", err)
+	}
+	if got.Root != "1-5759e988-bd862e3fe1be46a994272793" {
+		t.Errorf("want root 1-5759e988-bd862e3fe1be46a994272793 got %s", got.Root)
+	}
+}
+
+func TestZipkinTraceID(t *testing.T) {
+	var tests = []struct {
+		root    string
+		want    string
+		wantErr bool
+	}{
+		{"1-5759e988-bd862e3fe1be46a994272793", "5759e988bd862e3fe1be46a994272793", false},
+		{"1-5759E988-BD862E3FE1BE46A994272793", "5759e988bd862e3fe1be46a994272793", false},
+		{"2-5759e988-bd862e3fe1be46a994272793", "", true},
+		{"1-5759e988-bd862e3f", "", true},
+		{"1-5759e98g-bd862e3fe1be46a994272793", "", true},
+	}
+	for _, test := range tests {
+		got, err := ZipkinTraceID(test.root)
+		if (err != nil) != test.wantErr {
+			t.Errorf("root %s. want error %t got %+v", test.root, test.wantErr, err)
+		}
+		if got != test.want {
+			t.Errorf("root %s. want %s got %s", test.root, test.want, got)
+		}
+	}
+}
+
+func TestXRayInvocationSpan(t *testing.T) {
+	savedSendDatapoints, savedSendSpans, savedTracingEnabled, savedTraceIDFromXRay := sendDatapoints, sendSpans, tracingEnabled, traceIDFromXRay
+	defer func() {
+		sendDatapoints, sendSpans, tracingEnabled, traceIDFromXRay = savedSendDatapoints, savedSendSpans, savedTracingEnabled, savedTraceIDFromXRay
+	}()
+	sendDatapoints = func(context.Context, []*datapoint.Datapoint) error {
+		return nil
+	}
+	var got []*trace.Span
+	sendSpans = func(_ context.Context, spans []*trace.Span) error {
+		got = spans
+		return nil
+	}
+	tracingEnabled = true
+	c := context.WithValue(ctx, xrayTraceIDContextKey, "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+	for _, fromXRay := range []bool{false, true} {
+		traceIDFromXRay = fromXRay
+		got = nil
+		if _, err := NewHandlerWrapper(lambda.NewHandler(func() {})).Invoke(c, []byte(`""`)); err != nil {
+			t.Fatalf("valid lambda handler function invocation error. got %+v", err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("want 1 span got %d", len(got))
+		}
+		if tag := got[0].Tags[xrayTraceIDTag]; tag != "1-5759e988-bd862e3fe1be46a994272793" {
+			t.Errorf("want %s tag 1-5759e988-bd862e3fe1be46a994272793 got %s", xrayTraceIDTag, tag)
+		}
+		if isXRay := got[0].TraceID == "5759e988bd862e3fe1be46a994272793"; isXRay != fromXRay {
+			t.Errorf("want trace id from x-ray %t got %s", fromXRay, got[0].TraceID)
+		}
+	}
+}
+
+func TestXRayEventProperty(t *testing.T) {
+	savedSendEvents, savedXRayEventProperty := sendEvents, xrayEventProperty
+	defer func() {
+		sendEvents, xrayEventProperty = savedSendEvents, savedXRayEventProperty
+	}()
+	var got []*event.Event
+	sendEvents = func(_ context.Context, events []*event.Event) error {
+		got = events
+		return nil
+	}
+	c := context.WithValue(ctx, xrayTraceIDContextKey, "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+	hw := NewHandlerWrapper(lambda.NewHandler(func() {}))
+	for _, enabled := range []bool{false, true} {
+		xrayEventProperty = enabled
+		if err := hw.SendEventsContext(c, []*event.Event{{EventType: "deployment"}}); err != nil {
+			t.Fatalf("want no error got %+v", err)
+		}
+		property, ok := got[0].Properties[xrayTraceIDTag]
+		if ok != enabled || ok && property != "1-5759e988-bd862e3fe1be46a994272793" {
+			t.Errorf("want %s property %t got %v", xrayTraceIDTag, enabled, got[0].Properties)
+		}
+	}
+}